@@ -0,0 +1,159 @@
+package rbac
+
+import (
+	"crypto/x509"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CertField describes a field of the user's X.509 certificate which roles can be derived from.
+type CertField string
+
+// Certificate fields which can be mapped to roles.
+const (
+	CertFieldOU           CertField = "OU"
+	CertFieldOrganization CertField = "O"
+	CertFieldCommonName   CertField = "CN"
+	CertFieldLocality     CertField = "L"
+	CertFieldDNSName      CertField = "DNS"
+	CertFieldEmail        CertField = "email"
+	CertFieldURI          CertField = "URI"
+)
+
+// CertRoleRule maps a certificate field to roles. The user has the Roles if any value of the Field matches the
+// Pattern, which has the syntax of path.Match, except that `*` and `?` also match `/`, so "*" matches any value,
+// including URIs.
+// Fabric NodeOUs classify identities with the OUs "client", "peer", "admin" and "orderer".
+type CertRoleRule struct {
+	Field   CertField
+	Pattern string
+	Roles   []string
+}
+
+// certRoles returns the roles derived from the certificate by the rules.
+func certRoles(cert *x509.Certificate, rules []CertRoleRule) []string {
+	var roles []string
+
+	if cert == nil {
+		return roles
+	}
+
+	for _, rule := range rules {
+		re, err := patternRegexp(rule.Pattern)
+		if err != nil {
+			continue
+		}
+
+		for _, value := range certFieldValues(cert, rule.Field) {
+			if re.MatchString(value) {
+				roles = appendUnique(roles, rule.Roles...)
+				break
+			}
+		}
+	}
+
+	return roles
+}
+
+// certFieldValues returns all the values of a field in the certificate.
+func certFieldValues(cert *x509.Certificate, field CertField) []string {
+	switch field {
+	case CertFieldOU:
+		return cert.Subject.OrganizationalUnit
+	case CertFieldOrganization:
+		return cert.Subject.Organization
+	case CertFieldCommonName:
+		return []string{cert.Subject.CommonName}
+	case CertFieldLocality:
+		return cert.Subject.Locality
+	case CertFieldDNSName:
+		return cert.DNSNames
+	case CertFieldEmail:
+		return cert.EmailAddresses
+	case CertFieldURI:
+		uris := make([]string, len(cert.URIs))
+		for i, uri := range cert.URIs {
+			uris[i] = uri.String()
+		}
+
+		return uris
+	default:
+		return nil
+	}
+}
+
+// validateCertRoleRules checks that every rule has a known field and a well formed pattern.
+func validateCertRoleRules(rules []CertRoleRule) error {
+	for _, rule := range rules {
+		switch rule.Field {
+		case CertFieldOU, CertFieldOrganization, CertFieldCommonName, CertFieldLocality,
+			CertFieldDNSName, CertFieldEmail, CertFieldURI:
+		default:
+			return errors.Errorf("unknown certificate field `%v`", rule.Field)
+		}
+
+		if _, err := patternRegexp(rule.Pattern); err != nil {
+			return errors.Wrapf(err, "invalid %v pattern `%v`", rule.Field, rule.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// patternRegexp converts a pattern with the syntax of path.Match to a regular expression matching whole values.
+// Unlike path.Match, `*` and `?` match any character, as certificate values such as URIs aren't file paths.
+func patternRegexp(pattern string) (*regexp.Regexp, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+
+	b.WriteString(`(?s)^`)
+
+	for inClass, i := false, 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '\\' {
+			i++
+		} else {
+			switch {
+			case c == '*' && !inClass:
+				b.WriteString(`.*`)
+				continue
+			case c == '?' && !inClass:
+				b.WriteString(`.`)
+				continue
+			case c == '[' && !inClass:
+				inClass = true
+
+				b.WriteString(`[`)
+
+				if i+1 < len(pattern) && pattern[i+1] == '^' {
+					b.WriteString(`^`)
+					i++
+				}
+
+				continue
+			case c == ']' && inClass:
+				inClass = false
+
+				b.WriteString(`]`)
+
+				continue
+			case c == '-' && inClass:
+				b.WriteString(`-`)
+				continue
+			}
+		}
+
+		// Bytes of multi-byte characters are written one at a time, which QuoteMeta leaves unchanged
+		b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+	}
+
+	b.WriteString(`$`)
+
+	return regexp.Compile(b.String())
+}
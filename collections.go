@@ -0,0 +1,198 @@
+package rbac
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// ValidateCollectionPerms validates whether the given roles have permission to perform an operation on a private
+// data collection. The read operation requires read permission and all other operations require write permission.
+func (a AuthService) ValidateCollectionPerms(collection string, op Operation) error {
+	if !op.valid() {
+		return errOperationType(op)
+	}
+
+	for _, role := range a.userRoles {
+		// Lookup permissions
+		rule := a.rolePermissions[role].CollectionPermissions[collection]
+		if op == OperationRead && rule.Read || op != OperationRead && rule.Write {
+			return nil
+		}
+	}
+
+	return errCollection(collection, op)
+}
+
+// GetPrivateData returns the value of the key from the collection, with the user's query rule for the document's
+// docType applied in the same way as GetState. The user must be able to read the collection.
+func (a AuthService) GetPrivateData(collection, key string) ([]byte, error) {
+	if err := a.ValidateCollectionPerms(collection, OperationRead); err != nil {
+		return nil, err
+	}
+
+	value, err := a.stub.GetPrivateData(collection, key)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return a.filterRead(key, value)
+}
+
+// GetPrivateDataQueryResult executes a rich query against the collection, after it has been rewritten by
+// ValidateQueryPerms. The user must be able to read the collection.
+func (a AuthService) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	if err := a.ValidateCollectionPerms(collection, OperationRead); err != nil {
+		return nil, err
+	}
+
+	q, err := a.ValidateQueryPerms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := a.stub.GetPrivateDataQueryResult(collection, q)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return it, nil
+}
+
+// GetPrivateDataByRange returns an iterator over the key range of the collection, with the user's query rules
+// applied to each document. The user must be able to read the collection.
+func (a AuthService) GetPrivateDataByRange(
+	collection, startKey, endKey string,
+) (shim.StateQueryIteratorInterface, error) {
+	if err := a.ValidateCollectionPerms(collection, OperationRead); err != nil {
+		return nil, err
+	}
+
+	it, err := a.stub.GetPrivateDataByRange(collection, startKey, endKey)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return a.authorizeIterator(it), nil
+}
+
+// GetPrivateDataByPartialCompositeKey returns an iterator over the partial composite key of the collection, with
+// the user's query rules applied to each document. The user must be able to read the collection.
+func (a AuthService) GetPrivateDataByPartialCompositeKey(
+	collection, objectType string,
+	keys []string,
+) (shim.StateQueryIteratorInterface, error) {
+	if err := a.ValidateCollectionPerms(collection, OperationRead); err != nil {
+		return nil, err
+	}
+
+	it, err := a.stub.GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return a.authorizeIterator(it), nil
+}
+
+// GetPrivateData returns the value of the key from the collection, with the user's query rule for the document's
+// docType applied.
+func (s *AuthorizedStub) GetPrivateData(collection, key string) ([]byte, error) {
+	return s.auth.GetPrivateData(collection, key)
+}
+
+// GetPrivateDataQueryResult enforces the user's query permissions on the query before it is executed against
+// the collection.
+func (s *AuthorizedStub) GetPrivateDataQueryResult(
+	collection, query string,
+) (shim.StateQueryIteratorInterface, error) {
+	return s.auth.GetPrivateDataQueryResult(collection, query)
+}
+
+// GetPrivateDataByRange returns an iterator over the key range of the collection, with the user's query rules
+// applied to each document.
+func (s *AuthorizedStub) GetPrivateDataByRange(
+	collection, startKey, endKey string,
+) (shim.StateQueryIteratorInterface, error) {
+	return s.auth.GetPrivateDataByRange(collection, startKey, endKey)
+}
+
+// GetPrivateDataByPartialCompositeKey returns an iterator over the partial composite key of the collection, with
+// the user's query rules applied to each document.
+func (s *AuthorizedStub) GetPrivateDataByPartialCompositeKey(
+	collection, objectType string,
+	keys []string,
+) (shim.StateQueryIteratorInterface, error) {
+	return s.auth.GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+}
+
+// PutPrivateData writes the value to the collection if the user can write to the collection and has permission to
+// create or update the document's docType, in the same way as PutState.
+func (s *AuthorizedStub) PutPrivateData(collection, key string, value []byte) error {
+	if err := checkReservedKey(key); err != nil {
+		return err
+	}
+
+	if len(value) == 0 {
+		return s.DelPrivateData(collection, key)
+	}
+
+	if err := s.auth.ValidateCollectionPerms(collection, OperationUpdate); err != nil {
+		return err
+	}
+
+	existing, err := s.ChaincodeStubInterface.GetPrivateData(collection, key)
+	if err != nil {
+		return errLedger(err)
+	}
+
+	if err := s.auth.authorizePut(key, existing, value); err != nil {
+		return err
+	}
+
+	return s.ChaincodeStubInterface.PutPrivateData(collection, key, value)
+}
+
+// DelPrivateData deletes the key from the collection if the user can write to the collection and has permission
+// to delete the document's docType.
+func (s *AuthorizedStub) DelPrivateData(collection, key string) error {
+	if err := checkReservedKey(key); err != nil {
+		return err
+	}
+
+	if err := s.auth.ValidateCollectionPerms(collection, OperationDelete); err != nil {
+		return err
+	}
+
+	existing, err := s.ChaincodeStubInterface.GetPrivateData(collection, key)
+	if err != nil {
+		return errLedger(err)
+	}
+
+	if err := s.auth.authorizeWrite(key, existing, ""); err != nil {
+		return err
+	}
+
+	return s.ChaincodeStubInterface.DelPrivateData(collection, key)
+}
+
+// SetPrivateDataValidationParameter sets the key-level endorsement policy of the key in the collection if the user
+// can write to the collection and has permission to update the document stored under it, in the same way as
+// PutPrivateData.
+func (s *AuthorizedStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	if err := checkReservedKey(key); err != nil {
+		return err
+	}
+
+	if err := s.auth.ValidateCollectionPerms(collection, OperationUpdate); err != nil {
+		return err
+	}
+
+	existing, err := s.ChaincodeStubInterface.GetPrivateData(collection, key)
+	if err != nil {
+		return errLedger(err)
+	}
+
+	if err := s.auth.authorizeUpdate(key, existing); err != nil {
+		return err
+	}
+
+	return s.ChaincodeStubInterface.SetPrivateDataValidationParameter(collection, key, ep)
+}
@@ -0,0 +1,281 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+/*
+ *
+ * Create a pvtStub which implements private data deletes and records private data queries
+ *
+ */
+
+type pvtStub struct {
+	*shimtest.MockStub
+	queries []string
+}
+
+func (s *pvtStub) DelPrivateData(collection, key string) error {
+	delete(s.PvtState[collection], key)
+	return nil
+}
+
+func (s *pvtStub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	s.queries = append(s.queries, query)
+	return nil, nil
+}
+
+func initPvtStub(state map[string]string) *pvtStub {
+	stub := &pvtStub{MockStub: initEmptyStub()}
+
+	for k, v := range state {
+		_ = stub.PutPrivateData(collectionPvt, k, []byte(v))
+	}
+
+	return stub
+}
+
+func pvtPutContract(collection, key, value string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		return nil, stub.PutPrivateData(collection, key, []byte(value))
+	}
+}
+
+func pvtDelContract(collection, key string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		return nil, stub.DelPrivateData(collection, key)
+	}
+}
+
+func pvtGetContract(collection, key string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		return stub.GetPrivateData(collection, key)
+	}
+}
+
+func pvtEPContract(collection, key, ep string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		if err := stub.SetPrivateDataValidationParameter(collection, key, []byte(ep)); err != nil {
+			return nil, err
+		}
+
+		return stub.GetPrivateDataValidationParameter(collection, key)
+	}
+}
+
+func TestPrivateData(t *testing.T) {
+	tests := []struct {
+		c        rbac.ContractFunc
+		cidRoles string
+		state    map[string]string
+		expPL    string
+		expState map[string]string
+		msg      string
+	}{
+		{
+			c:        pvtPutContract(collectionPvt, "w1", walletDoc),
+			cidRoles: "user",
+			expState: map[string]string{"w1": walletDoc},
+			msg:      "Should allow the user to create a wallet in a collection they can write",
+		},
+		{
+			c:        pvtPutContract(collectionPvt, "w1", richWalletDoc),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expState: map[string]string{"w1": richWalletDoc},
+			msg:      "Should allow the user to update a wallet in a collection they can write",
+		},
+		{
+			c:        pvtGetContract(collectionPvt, "w1"),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expPL:    walletDoc,
+			msg:      "Should return the user's wallet from a collection they can read",
+		},
+		{
+			c:        pvtGetContract(collectionPvt, "t1"),
+			cidRoles: "auditor",
+			state:    map[string]string{"t1": doneTransferDoc},
+			expPL:    `{"createdBy": "anotherUserID", "created": 1, "status": "completed"}`,
+			msg:      "Should return only the allowed fields of a document in a collection",
+		},
+		{
+			c:        pvtDelContract(collectionPvt, "w1"),
+			cidRoles: "user,admin",
+			state:    map[string]string{"w1": walletDoc},
+			expState: map[string]string{},
+			msg:      "Should allow the admin to delete a wallet they can read in a collection they can write",
+		},
+		{
+			c:        pvtEPContract(collectionPvt, "w1", `"ep"`),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expPL:    `"ep"`,
+			msg:      "Should allow the user to set the validation parameter of a wallet in a collection they can write",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		stub := initPvtStub(tt.state)
+		appAuth := simpleSetup(t, stub, nil, tt.cidRoles)
+
+		payload, err := tt.c(appAuth.AuthorizedStub(), []string{}, appAuth)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		if tt.expPL != "" {
+			assert.JSONEq(t, tt.expPL, string(payload))
+		}
+
+		if tt.expState != nil {
+			assert.Len(t, stub.PvtState[collectionPvt], len(tt.expState))
+
+			for k, v := range tt.expState {
+				assert.Equal(t, v, string(stub.PvtState[collectionPvt][k]))
+			}
+		}
+	}
+}
+
+func TestPrivateDataQuery(t *testing.T) {
+	stub := initPvtStub(nil)
+	appAuth := simpleSetup(t, stub, nil, "user")
+
+	t.Log("Should rewrite queries executed against a collection")
+
+	_, err := appAuth.AuthorizedStub().GetPrivateDataQueryResult(collectionPvt, doctypeQuery(resourceWallet))
+	assert.NoError(t, err)
+
+	if assert.Len(t, stub.queries, 1) {
+		assert.JSONEq(t, expQueryOnlyCreatedBy(resourceWallet), stub.queries[0])
+	}
+
+	t.Log("Should not execute queries against a collection the user can't read")
+
+	_, err = appAuth.GetPrivateDataQueryResult("otherPvt", doctypeQuery(resourceWallet))
+	assert.Error(t, err)
+	assert.Len(t, stub.queries, 1)
+}
+
+func TestPrivateDataErrors(t *testing.T) {
+	tests := []struct {
+		c        rbac.ContractFunc
+		cidRoles string
+		state    map[string]string
+		expSC    int32
+		expC     int32
+		msg      string
+	}{
+		{
+			c:        pvtGetContract(collectionPvt, "w1"),
+			cidRoles: "admin",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrCollection,
+			msg:      "when the user can't read the collection",
+		},
+		{
+			c:        pvtPutContract(collectionPvt, "w1", walletDoc),
+			cidRoles: "auditor",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrCollection,
+			msg:      "when the user can't write to the collection",
+		},
+		{
+			c:        pvtPutContract(collectionPvt, policyKey, policyDoc),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrReservedKey,
+			msg:      "when the user writes a reserved key to a collection",
+		},
+		{
+			c:        pvtDelContract(collectionPvt, policyKey),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrReservedKey,
+			msg:      "when the user deletes a reserved key from a collection",
+		},
+		{
+			c:        pvtPutContract(collectionPvt, "w1", walletDoc),
+			cidRoles: "user",
+			state:    map[string]string{"w1": otherWalletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocument,
+			msg:      "when the user overwrites another user's wallet in a collection",
+		},
+		{
+			c:        pvtEPContract(collectionPvt, "w1", `"ep"`),
+			cidRoles: "user",
+			state:    map[string]string{"w1": otherWalletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocument,
+			msg:      "when the user sets the validation parameter of another user's wallet in a collection",
+		},
+		{
+			c:        pvtEPContract(collectionPvt, "w1", `"ep"`),
+			cidRoles: "auditor",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrCollection,
+			msg:      "when the user sets a validation parameter in a collection they can't write",
+		},
+		{
+			c:        pvtEPContract(collectionPvt, policyKey, `"ep"`),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrReservedKey,
+			msg:      "when the user sets the validation parameter of a reserved key in a collection",
+		},
+		{
+			c:        pvtDelContract("otherPvt", "w1"),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrCollection,
+			msg:      "when the user deletes from a collection they can't write",
+		},
+		{
+			c:        pvtGetContract(collectionPvt, "w1"),
+			cidRoles: "user",
+			state:    map[string]string{"w1": otherWalletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrQuery,
+			msg:      "when the document doesn't match the user's query rule",
+		},
+		{
+			c:        pvtDelContract(collectionPvt, "w1"),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrOperation,
+			msg:      "when the user deletes a forbidden docType from a collection",
+		},
+	}
+
+	for _, tt := range tests {
+		stub := initPvtStub(tt.state)
+		appAuth := simpleSetup(t, stub, nil, tt.cidRoles)
+
+		_, err := tt.c(appAuth.AuthorizedStub(), []string{}, appAuth)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v", tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+
+		assert.Len(t, stub.PvtState[collectionPvt], len(tt.state))
+	}
+}
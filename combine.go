@@ -0,0 +1,288 @@
+package rbac
+
+// QueryCombining describes how the QueryRules of a user's roles are combined in to a single QueryRule.
+type QueryCombining int
+
+const (
+	// QueryCombineUnion allows the records and fields allowed by any of the user's roles. The selectors of the
+	// roles are combined with `$or` and the field filters are combined, where no filter means all fields.
+	// If the roles differ in both selector and field filter, only the fields allowed by every role are returned,
+	// so that no record is returned with more fields than a role which can see it allows.
+	QueryCombineUnion QueryCombining = iota
+	// QueryCombineFirstMatch uses the rule of the first role, in the order of the user's roles, which allows the query.
+	QueryCombineFirstMatch
+	// QueryCombineMostPermissive uses the single least restrictive rule which allows the query. A rule without a
+	// selector is less restrictive than one with a selector, then a rule with more fields is less restrictive.
+	QueryCombineMostPermissive
+	// QueryCombineMostRestrictive only allows the query if every role with a rule for the resource allows it.
+	// The selectors of the roles are combined with `$and` and only fields allowed by all the roles are returned.
+	QueryCombineMostRestrictive
+)
+
+// PermissionCombining describes how the contract and operation permissions of a user's roles are combined,
+// where a permission set to false is an explicit deny and a missing permission doesn't apply.
+type PermissionCombining int
+
+const (
+	// PermitOverrides allows if any of the user's roles allows, so an explicit deny means the same as no permission.
+	PermitOverrides PermissionCombining = iota
+	// DenyOverrides denies if any of the user's roles explicitly denies, otherwise allows if any role allows.
+	DenyOverrides
+	// FirstApplicable uses the permission of the first role, in the order of the user's roles, which sets it.
+	FirstApplicable
+)
+
+// permitted combines a permission of each of the user's roles. lookup returns a role's permission and whether the
+// role sets it. Nothing is permitted unless a role allows it.
+func (a AuthService) permitted(lookup func(perms Permissions) (allow bool, ok bool)) bool {
+	permit := false
+
+	for _, role := range a.userRoles {
+		// Lookup permissions
+		allow, ok := lookup(a.rolePermissions[role])
+		if !ok {
+			continue
+		}
+
+		switch {
+		case a.permissionCombining == FirstApplicable:
+			return allow
+		case allow && a.permissionCombining == PermitOverrides:
+			return true
+		case !allow && a.permissionCombining == DenyOverrides:
+			return false
+		}
+
+		permit = permit || allow
+	}
+
+	return permit
+}
+
+// queryRule returns the user's QueryRule for a resource, combining the rules of all the user's roles.
+// The returned bool is false if the user is not allowed to query the resource.
+func (a AuthService) queryRule(resource string) (QueryRule, bool, error) {
+	var allowed []QueryRule
+
+	for _, role := range a.userRoles {
+		// Lookup permissions
+		ruleFunc, ok := a.rolePermissions[role].queryRuleFunc(resource)
+		if !ok {
+			continue
+		}
+
+		// Construct rules from the ruleFunc callback
+		rule, err := ruleFunc(a.ruleContext)
+		if err != nil {
+			return QueryRule{}, false, errRule(resource, err)
+		}
+
+		// A rule with an empty field filter allows no fields, so it doesn't allow anything
+		if !rule.Allow || (rule.FieldFilter != nil && len(rule.FieldFilter) == 0) {
+			if a.queryCombining == QueryCombineMostRestrictive {
+				return QueryRule{}, false, nil
+			}
+
+			continue
+		}
+
+		if a.queryCombining == QueryCombineFirstMatch {
+			return rule, true, nil
+		}
+
+		allowed = append(allowed, rule)
+	}
+
+	if len(allowed) == 0 {
+		return QueryRule{}, false, nil
+	}
+
+	switch a.queryCombining {
+	case QueryCombineMostPermissive:
+		return mostPermissiveRule(allowed), true, nil
+	case QueryCombineMostRestrictive:
+		rule, ok := intersectRules(allowed)
+		return rule, ok, nil
+	default:
+		rule, ok := unionRules(allowed)
+		return rule, ok, nil
+	}
+}
+
+// unionRules combines rules so that anything allowed by any of the rules is allowed.
+// A query has a single field filter, so if the rules have different selectors and different field filters only the
+// fields allowed by every rule are returned. Otherwise a document matching one rule's selector would be returned
+// with the fields of another rule. Rules which allow nothing more than another rule are ignored.
+// The returned bool is false if the rules have no fields in common.
+func unionRules(rules []QueryRule) (QueryRule, bool) {
+	union := QueryRule{Allow: true}
+	rules = withoutSubsumedRules(rules)
+
+	var selectors []interface{}
+
+	unrestricted := false
+	sameSelectors := true
+	sameFields := true
+
+	for _, rule := range rules {
+		if len(rule.SelectorAppend) == 0 {
+			unrestricted = true
+		} else {
+			selectors = append(selectors, rule.SelectorAppend)
+		}
+
+		sameSelectors = sameSelectors && sameJSON(selectorOrNil(rule), selectorOrNil(rules[0]))
+		sameFields = sameFields && sameJSON(rule.FieldFilter, rules[0].FieldFilter)
+	}
+
+	if sameSelectors || sameFields {
+		union.FieldFilter = unionFieldFilters(rules)
+	} else {
+		union.FieldFilter = intersectFieldFilters(rules)
+	}
+
+	if union.FieldFilter != nil && len(union.FieldFilter) == 0 {
+		return QueryRule{}, false
+	}
+
+	switch {
+	case unrestricted:
+	case len(selectors) == 1:
+		union.SelectorAppend = selectors[0].(CDBSelector)
+	default:
+		union.SelectorAppend = CDBSelector{"$or": selectors}
+	}
+
+	return union, true
+}
+
+// withoutSubsumedRules returns the rules without those which allow nothing more than another of the rules,
+// keeping the first of any identical rules.
+func withoutSubsumedRules(rules []QueryRule) []QueryRule {
+	var kept []QueryRule
+
+	for i, rule := range rules {
+		subsumed := false
+
+		for j, other := range rules {
+			if i != j && subsumes(other, rule) && (j < i || !subsumes(rule, other)) {
+				subsumed = true
+				break
+			}
+		}
+
+		if !subsumed {
+			kept = append(kept, rule)
+		}
+	}
+
+	return kept
+}
+
+// subsumes returns whether rule a allows everything rule b allows, i.e. a's selector is empty or the same as b's
+// and a allows all of b's fields.
+func subsumes(a, b QueryRule) bool {
+	if len(a.SelectorAppend) > 0 && !sameJSON(a.SelectorAppend, b.SelectorAppend) {
+		return false
+	}
+
+	if a.FieldFilter == nil {
+		return true
+	}
+
+	if b.FieldFilter == nil {
+		return false
+	}
+
+	for _, f := range b.FieldFilter {
+		if !contains(a.FieldFilter, f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// intersectRules combines rules so that only what is allowed by all of the rules is allowed.
+// The returned bool is false if the rules have no fields in common.
+func intersectRules(rules []QueryRule) (QueryRule, bool) {
+	intersection := QueryRule{Allow: true}
+
+	for _, rule := range rules {
+		intersection.SelectorAppend = mergeSelectors(intersection.SelectorAppend, rule.SelectorAppend)
+	}
+
+	intersection.FieldFilter = intersectFieldFilters(rules)
+
+	if intersection.FieldFilter != nil && len(intersection.FieldFilter) == 0 {
+		return QueryRule{}, false
+	}
+
+	if len(intersection.SelectorAppend) == 0 {
+		intersection.SelectorAppend = nil
+	}
+
+	return intersection, true
+}
+
+// unionFieldFilters returns the fields allowed by any of the rules, where no field filter means all fields.
+func unionFieldFilters(rules []QueryRule) []string {
+	var fields []string
+
+	for _, rule := range rules {
+		if rule.FieldFilter == nil {
+			return nil
+		}
+
+		fields = appendUnique(fields, rule.FieldFilter...)
+	}
+
+	return fields
+}
+
+// intersectFieldFilters returns the fields allowed by all of the rules, where no field filter means all fields.
+func intersectFieldFilters(rules []QueryRule) []string {
+	var fields []string
+
+	for _, rule := range rules {
+		switch {
+		case rule.FieldFilter == nil:
+		case fields == nil:
+			fields = rule.FieldFilter
+		default:
+			fields = intersectFields(fields, rule.FieldFilter)
+		}
+	}
+
+	return fields
+}
+
+// selectorOrNil returns the rule's selector, or nil if it has no conditions.
+func selectorOrNil(rule QueryRule) CDBSelector {
+	if len(rule.SelectorAppend) == 0 {
+		return nil
+	}
+
+	return rule.SelectorAppend
+}
+
+// mostPermissiveRule returns the least restrictive of the rules, preferring the first on a tie.
+func mostPermissiveRule(rules []QueryRule) QueryRule {
+	best := rules[0]
+
+	for _, rule := range rules[1:] {
+		bestRestricted, restricted := len(best.SelectorAppend) > 0, len(rule.SelectorAppend) > 0
+
+		switch {
+		case bestRestricted != restricted:
+			if !restricted {
+				best = rule
+			}
+		case best.FieldFilter == nil:
+		case rule.FieldFilter == nil || len(rule.FieldFilter) > len(best.FieldFilter):
+			best = rule
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,169 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+func getConditionRolePerms() rbac.RolePermissions {
+	rp := getRolePerms()
+
+	user := rp["user"]
+	user.ContractConditions = rbac.ContractConditions{
+		contractCreateWallet: forSelf,
+	}
+	rp["user"] = user
+
+	rp["teller"] = rbac.Permissions{
+		ContractPermissions: rbac.ContractPermissions{
+			contractCreateTransfer: true,
+		},
+		ContractConditions: rbac.ContractConditions{
+			contractCreateTransfer: maxAmount(10000),
+		},
+	}
+
+	return rp
+}
+
+func TestContractConditions(t *testing.T) {
+	tests := []struct {
+		cRef      string
+		args      []string
+		cidRoles  string
+		combining rbac.PermissionCombining
+		msg       string
+	}{
+		{
+			cRef:     contractCreateTransfer,
+			args:     []string{"10000"},
+			cidRoles: "teller",
+			msg:      "Should allow a teller to create a transfer up to the limit",
+		},
+		{
+			cRef:     contractCreateTransfer,
+			args:     []string{"50000"},
+			cidRoles: "teller,admin",
+			msg:      "Should allow a transfer over the limit when another role has no condition",
+		},
+		{
+			cRef:     contractCreateWallet,
+			args:     []string{"testuserID"},
+			cidRoles: "user",
+			msg:      "Should allow a user to create a wallet for themselves",
+		},
+		{
+			cRef:      contractCreateTransfer,
+			args:      []string{"50000"},
+			cidRoles:  "admin,teller",
+			combining: rbac.FirstApplicable,
+			msg:       "Should allow a transfer over the limit with FirstApplicable when the first role has no condition",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		appAuth := simpleSetup(t, nil, getConditionRolePerms(), tt.cidRoles, rbac.WithPermissionCombining(tt.combining))
+		payload, err := appAuth.WithContractAuth(tt.cRef, tt.args, mockContract)
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockPayload, payload)
+	}
+}
+
+func TestContractConditionErrors(t *testing.T) {
+	tests := []struct {
+		cRef     string
+		args     []string
+		cidRoles string
+		msg      string
+	}{
+		{
+			cRef:     contractCreateTransfer,
+			args:     []string{"10001"},
+			cidRoles: "teller",
+			msg:      "when a teller creates a transfer over the limit",
+		},
+		{
+			cRef:     contractCreateTransfer,
+			args:     []string{},
+			cidRoles: "teller",
+			msg:      "when the args the condition needs are missing",
+		},
+		{
+			cRef:     contractCreateWallet,
+			args:     []string{"anotherUserID"},
+			cidRoles: "user",
+			msg:      "when a user creates a wallet for someone else",
+		},
+	}
+
+	for _, tt := range tests {
+		appAuth := simpleSetup(t, nil, getConditionRolePerms(), tt.cidRoles)
+		payload, err := appAuth.WithContractAuth(tt.cRef, tt.args, mockContract)
+
+		assert.Nil(t, payload)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v",
+				rbac.CodeErrContractArgs, http.StatusForbidden, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrContractArgs), e.Code())
+				assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+			}
+		}
+	}
+}
+
+func TestContractConditionCombiningErrors(t *testing.T) {
+	tests := []struct {
+		cRef      string
+		args      []string
+		cidRoles  string
+		combining rbac.PermissionCombining
+		msg       string
+	}{
+		{
+			cRef:      contractCreateTransfer,
+			args:      []string{"100"},
+			cidRoles:  "teller,suspended",
+			combining: rbac.DenyOverrides,
+			msg:       "when another role denies the contract with DenyOverrides",
+		},
+		{
+			cRef:      contractCreateTransfer,
+			args:      []string{"50000"},
+			cidRoles:  "teller,admin",
+			combining: rbac.DenyOverrides,
+			msg:       "when a role's condition is false with DenyOverrides, even though another role has no condition",
+		},
+		{
+			cRef:      contractCreateTransfer,
+			args:      []string{"50000"},
+			cidRoles:  "teller,admin",
+			combining: rbac.FirstApplicable,
+			msg:       "when the first role's condition is false with FirstApplicable",
+		},
+	}
+
+	for _, tt := range tests {
+		appAuth := simpleSetup(t, nil, getConditionRolePerms(), tt.cidRoles, rbac.WithPermissionCombining(tt.combining))
+		err := appAuth.ValidateContractArgs(tt.cRef, tt.args)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v",
+				rbac.CodeErrContractArgs, http.StatusForbidden, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrContractArgs), e.Code())
+				assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package rbac
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
+)
+
+// RuleContext describes the context a QueryRuleContextFunc is evaluated in.
+// The MSP ID, attributes and tx timestamp are read when they are first used, so a rule only depends on the
+// parts of the identity and transaction it uses.
+type RuleContext struct {
+	Stub           shim.ChaincodeStubInterface
+	ClientIdentity cid.ClientIdentity
+	UserID         string
+	UserRoles      []string
+
+	mspID string
+	attrs map[string]string
+}
+
+// MSPID returns the MSP ID of the user's identity.
+func (c *RuleContext) MSPID() (string, error) {
+	if c.mspID != "" {
+		return c.mspID, nil
+	}
+
+	mspID, err := c.ClientIdentity.GetMSPID()
+	if err != nil {
+		return "", errAuthentication(err)
+	}
+
+	c.mspID = mspID
+
+	return mspID, nil
+}
+
+// Attributes returns all the attributes in the user's X.509 certificate.
+// Identities without an X.509 certificate have no attributes.
+func (c *RuleContext) Attributes() (map[string]string, error) {
+	if c.attrs != nil {
+		return c.attrs, nil
+	}
+
+	cert, err := c.ClientIdentity.GetX509Certificate()
+	if err != nil {
+		return nil, errAuthentication(err)
+	}
+
+	c.attrs = map[string]string{}
+
+	if cert == nil {
+		return c.attrs, nil
+	}
+
+	attrs, err := attrmgr.New().GetAttributesFromCert(cert)
+	if err != nil {
+		c.attrs = nil
+		return nil, errAuthentication(err)
+	}
+
+	for name, value := range attrs.Attrs {
+		c.attrs[name] = value
+	}
+
+	return c.attrs, nil
+}
+
+// TxTimestamp returns the timestamp of the transaction, which is the same on all endorsing peers.
+func (c *RuleContext) TxTimestamp() (time.Time, error) {
+	ts, err := c.Stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errLedger(err)
+	}
+
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Time{}, errLedger(errors.Wrap(err, "invalid tx timestamp"))
+	}
+
+	return t, nil
+}
+
+// ChannelID returns the channel the transaction was submitted on.
+func (c *RuleContext) ChannelID() string {
+	return c.Stub.GetChannelID()
+}
+
+// ContextFunc adapts a QueryRuleFunc to a QueryRuleContextFunc, so it can be used in QueryContextPermissions.
+func (f QueryRuleFunc) ContextFunc() QueryRuleContextFunc {
+	return func(ctx *RuleContext) (QueryRule, error) {
+		return f(ctx.UserID, ctx.UserRoles), nil
+	}
+}
+
+// queryRuleFunc returns the role's rule for a resource and whether the role has one.
+// A rule in QueryContextPermissions takes precedence over one in QueryPermissions.
+func (p Permissions) queryRuleFunc(resource string) (QueryRuleContextFunc, bool) {
+	if ruleFunc, ok := p.QueryContextPermissions[resource]; ok {
+		return ruleFunc, true
+	}
+
+	if ruleFunc, ok := p.QueryPermissions[resource]; ok {
+		return ruleFunc.ContextFunc(), true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,139 @@
+package rbac_test
+
+import (
+	"crypto/x509"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+const resourceReport = "report"
+
+func getContextRolePerms() rbac.RolePermissions {
+	return rbac.RolePermissions{
+		"member": {
+			QueryContextPermissions: rbac.QueryContextPermissions{
+				resourceAsset:    sameOrg,
+				resourceReport:   sameDepartment,
+				resourceTransfer: activeAtTx,
+				resourceWallet:   rbac.QueryRuleFunc(owner).ContextFunc(),
+			},
+		},
+		"broken": {
+			QueryContextPermissions: rbac.QueryContextPermissions{
+				resourceAsset: failing,
+			},
+		},
+	}
+}
+
+// financeIdentity returns an Org1MSP identity with the roles and a certificate with the finance department attribute.
+func financeIdentity(t *testing.T, userRoles string) identity {
+	cert := &x509.Certificate{}
+	attrs := &attrmgr.Attributes{Attrs: map[string]string{"department": "finance"}}
+
+	if err := attrmgr.New().AddAttributesToCert(attrs, cert); err != nil {
+		t.Fatalf("Adding certificate attributes failed unexpectedly")
+	}
+
+	return identity{roles: userRoles, mspID: "Org1MSP", cert: cert}
+}
+
+// contextStub returns an empty stub in a transaction with a fixed timestamp.
+func contextStub() *shimtest.MockStub {
+	stub := initEmptyStub()
+	stub.MockTransactionStart("tx")
+	stub.TxTimestamp = &timestamp.Timestamp{Seconds: 1600000000}
+
+	return stub
+}
+
+func TestQueryRuleContext(t *testing.T) {
+	tests := []struct {
+		res  string
+		expQ string
+		msg  string
+	}{
+		{
+			res:  resourceAsset,
+			expQ: `{"selector": {"docType": "asset", "org": "Org1MSP"}, "limit": 10}`,
+			msg:  "Should restrict the query to the user's MSP",
+		},
+		{
+			res:  resourceReport,
+			expQ: `{"selector": {"docType": "report", "department": "finance"}, "limit": 10}`,
+			msg:  "Should restrict the query by a certificate attribute",
+		},
+		{
+			res:  resourceTransfer,
+			expQ: `{"selector": {"docType": "transfer", "validFrom": {"$lte": 1600000000}}, "limit": 10}`,
+			msg:  "Should restrict the query by the tx timestamp",
+		},
+		{
+			res:  resourceWallet,
+			expQ: expQueryOnlyCreatedBy(resourceWallet),
+			msg:  "Should evaluate a QueryRuleFunc adapted to a QueryRuleContextFunc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		cid := newMockCID(financeIdentity(t, "member"))
+
+		appAuth, err := rbac.New(contextStub(), cid, getContextRolePerms(), "roles")
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		payload, err := appAuth.ValidateQueryPerms(doctypeQuery(tt.res))
+
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, tt.expQ, payload)
+		}
+	}
+
+	t.Log("Should filter documents read by key with the RuleContext")
+
+	stub := contextStub()
+
+	appAuth, err := rbac.New(stub, newMockCID(financeIdentity(t, "member")), getContextRolePerms(), "roles")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, stub.PutState("a1", []byte(`{"docType": "asset", "org": "Org1MSP"}`)))
+	assert.NoError(t, stub.PutState("a2", []byte(`{"docType": "asset", "org": "Org2MSP"}`)))
+
+	payload, err := appAuth.GetState("a1")
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"docType": "asset", "org": "Org1MSP"}`, string(payload))
+	}
+
+	_, err = appAuth.GetState("a2")
+	assert.Error(t, err)
+}
+
+func TestQueryRuleContextErrors(t *testing.T) {
+	t.Log("Should return an error when a query rule fails")
+
+	cid := newMockCID(financeIdentity(t, "member,broken"))
+
+	appAuth, err := rbac.New(contextStub(), cid, getContextRolePerms(), "roles")
+	if assert.NoError(t, err) {
+		_, err = appAuth.ValidateQueryPerms(doctypeQuery(resourceAsset))
+	}
+
+	if assert.Error(t, err) {
+		if e, ok := err.(rbac.AuthErrorInterface); ok {
+			assert.Equal(t, int32(rbac.CodeErrRule), e.Code())
+			assert.Equal(t, int32(http.StatusInternalServerError), e.StatusCode())
+		}
+	}
+}
@@ -51,13 +51,36 @@ func (e authError) StatusCode() int32 {
 }
 
 // Error Codes for identifying error types.
+// The first three digits of a code are the suggested HTTP status code and the last is a sequence number, except
+// for the 490x range, which holds further http.StatusForbidden errors as the 403x range is full.
 const (
-	CodeErrQueryMarshal   = 4001
-	CodeErrQueryDocType   = 4002
-	CodeErrAuthentication = 4011
-	CodeErrRoles          = 4031
-	CodeErrContract       = 4032
-	CodeErrQuery          = 4033
+	CodeErrQueryMarshal         = 4001
+	CodeErrQueryDocType         = 4002
+	CodeErrOperationType        = 4003
+	CodeErrPolicy               = 4004
+	CodeErrQueryDocTypeOperator = 4005
+	CodeErrSelector             = 4006
+	CodeErrRolesAttr            = 4007
+	CodeErrRoleAssignment       = 4008
+	CodeErrAuthentication       = 4011
+	CodeErrRoles                = 4031
+	CodeErrContract             = 4032
+	CodeErrQuery                = 4033
+	CodeErrOperation            = 4034
+	CodeErrQueryField           = 4035
+	CodeErrQueryFields          = 4036
+	CodeErrDocType              = 4037
+	CodeErrUnknownDocType       = 4038
+	CodeErrHistory              = 4039
+	CodeErrCollection           = 4901
+	CodeErrContractArgs         = 4902
+	CodeErrUnknownRole          = 4903
+	CodeErrReservedKey          = 4904
+	CodeErrDocument             = 4905
+	CodeErrHiddenFields         = 4906
+	CodeErrLedger               = 5001
+	CodeErrConfig               = 5002
+	CodeErrRule                 = 5003
 )
 
 // errAuthentication for authentication errors (user could not be authenticated).
@@ -82,6 +105,39 @@ func errRoles(role string) authError {
 	}
 }
 
+// errRolesAttr error.
+func errRolesAttr(attr string, err error) authError {
+	err = errors.Wrapf(err, "malformed `%v` attribute", attr)
+
+	return authError{
+		err:    err,
+		code:   CodeErrRolesAttr,
+		status: http.StatusBadRequest,
+	}
+}
+
+// errRoleAssignment error.
+func errRoleAssignment(err error) authError {
+	err = errors.Wrap(err, "invalid role assignment")
+
+	return authError{
+		err:    err,
+		code:   CodeErrRoleAssignment,
+		status: http.StatusBadRequest,
+	}
+}
+
+// errUnknownRole error.
+func errUnknownRole(role string) authError {
+	err := errors.Errorf("user role `%v` is not defined in the role permissions", role)
+
+	return authError{
+		err:    err,
+		code:   CodeErrUnknownRole,
+		status: http.StatusForbidden,
+	}
+}
+
 // errContract error.
 func errContract() authError {
 	err := errors.New("user doesn't have permission to invoke this contract")
@@ -93,6 +149,17 @@ func errContract() authError {
 	}
 }
 
+// errContractArgs error.
+func errContractArgs(contractName string) authError {
+	err := errors.Errorf("user doesn't have permission to invoke %v with these arguments", contractName)
+
+	return authError{
+		err:    err,
+		code:   CodeErrContractArgs,
+		status: http.StatusForbidden,
+	}
+}
+
 // errQuery error.
 func errQuery(res string) authError {
 	err := errors.Errorf("user doesn't have permission to query %v records", res)
@@ -104,6 +171,175 @@ func errQuery(res string) authError {
 	}
 }
 
+// errQueryField error.
+func errQueryField(field string) authError {
+	err := errors.Errorf("user doesn't have permission to query by the %v field", field)
+
+	return authError{
+		err:    err,
+		code:   CodeErrQueryField,
+		status: http.StatusForbidden,
+	}
+}
+
+// errQueryFields error.
+func errQueryFields() authError {
+	err := errors.New("user doesn't have permission to see any of the requested fields")
+
+	return authError{
+		err:    err,
+		code:   CodeErrQueryFields,
+		status: http.StatusForbidden,
+	}
+}
+
+// errDocType error.
+func errDocType(key string) authError {
+	err := errors.Errorf("document %v is not JSON with a docType, so it can't be authorised", key)
+
+	return authError{
+		err:    err,
+		code:   CodeErrDocType,
+		status: http.StatusForbidden,
+	}
+}
+
+// errUnknownDocType error.
+func errUnknownDocType(docType string) authError {
+	err := errors.Errorf("no role has query permissions for %v records, so they can't be authorised", docType)
+
+	return authError{
+		err:    err,
+		code:   CodeErrUnknownDocType,
+		status: http.StatusForbidden,
+	}
+}
+
+// errHistory error.
+func errHistory(res string) authError {
+	err := errors.Errorf("user doesn't have permission to see the history of %v records", res)
+
+	return authError{
+		err:    err,
+		code:   CodeErrHistory,
+		status: http.StatusForbidden,
+	}
+}
+
+// errCollection error.
+func errCollection(collection string, op Operation) authError {
+	err := errors.Errorf("user doesn't have permission to %v the %v private data collection", op, collection)
+
+	return authError{
+		err:    err,
+		code:   CodeErrCollection,
+		status: http.StatusForbidden,
+	}
+}
+
+// errReservedKey error.
+func errReservedKey(key string) authError {
+	err := errors.Errorf("key %q is reserved for access control records", key)
+
+	return authError{
+		err:    err,
+		code:   CodeErrReservedKey,
+		status: http.StatusForbidden,
+	}
+}
+
+// errDocument error.
+func errDocument(key string, op Operation) authError {
+	err := errors.Errorf("user doesn't have permission to %v document %v", op, key)
+
+	return authError{
+		err:    err,
+		code:   CodeErrDocument,
+		status: http.StatusForbidden,
+	}
+}
+
+// errHiddenFields error.
+func errHiddenFields(key string) authError {
+	err := errors.Errorf("user can't change the fields of document %v which they aren't allowed to see", key)
+
+	return authError{
+		err:    err,
+		code:   CodeErrHiddenFields,
+		status: http.StatusForbidden,
+	}
+}
+
+// errOperation error.
+func errOperation(res string, op Operation) authError {
+	err := errors.Errorf("user doesn't have permission to %v %v records", op, res)
+
+	return authError{
+		err:    err,
+		code:   CodeErrOperation,
+		status: http.StatusForbidden,
+	}
+}
+
+// errOperationType error.
+func errOperationType(op Operation) authError {
+	err := errors.Errorf("`%v` is not a valid operation, must be one of create, read, update or delete", op)
+
+	return authError{
+		err:    err,
+		code:   CodeErrOperationType,
+		status: http.StatusBadRequest,
+	}
+}
+
+// errPolicy error.
+func errPolicy(err error) authError {
+	err = errors.Wrap(err, "invalid policy")
+
+	return authError{
+		err:    err,
+		code:   CodeErrPolicy,
+		status: http.StatusBadRequest,
+	}
+}
+
+// errLedger error.
+func errLedger(err error) authError {
+	err = errors.Wrap(err, "ledger access failed")
+
+	return authError{
+		err:    err,
+		code:   CodeErrLedger,
+		status: http.StatusInternalServerError,
+	}
+}
+
+// errConfig error.
+func errConfig(err error) authError {
+	err = errors.Wrap(err, "invalid role permissions")
+
+	return authError{
+		err:    err,
+		code:   CodeErrConfig,
+		status: http.StatusInternalServerError,
+	}
+}
+
+// errRule error.
+func errRule(resource string, err error) error {
+	if _, ok := err.(AuthErrorInterface); ok {
+		return err
+	}
+
+	err = errors.Wrapf(err, "query rule for %v failed", resource)
+
+	return authError{
+		err:    err,
+		code:   CodeErrRule,
+		status: http.StatusInternalServerError,
+	}
+}
+
 // errQueryMarshal error.
 func errQueryMarshal(err error) authError {
 	err = errors.Wrap(err, "could not marshal query")
@@ -126,6 +362,28 @@ func errQueryDocType() authError {
 	}
 }
 
+// errQueryDocTypeOperator error.
+func errQueryDocTypeOperator(op string) authError {
+	err := errors.Errorf("`%v` operator is not supported on docType, use a string, `$eq` or `$in`", op)
+
+	return authError{
+		err:    err,
+		code:   CodeErrQueryDocTypeOperator,
+		status: http.StatusBadRequest,
+	}
+}
+
+// errSelector error.
+func errSelector(op string) authError {
+	err := errors.Errorf("selector `%v` operator is invalid or unsupported", op)
+
+	return authError{
+		err:    err,
+		code:   CodeErrSelector,
+		status: http.StatusBadRequest,
+	}
+}
+
 // errMarshal error.
 func errMarshal(err error) authError {
 	err = errors.Wrap(err, "Marshal failed")
@@ -49,3 +49,95 @@ func expQueryLimitFields(r string) string {
   ]
 }`
 }
+
+const expQueryCompleted = `
+{
+  "selector": {
+    "docType": "transfer",
+    "status": "completed"
+  },
+  "limit": 10,
+  "fields": [
+    "createdBy",
+    "created",
+    "status"
+  ]
+}`
+
+const expQueryInTransferOrCompleted = `
+{
+  "selector": {
+    "$or": [
+      {
+        "$or": [
+          { "createdBy": "testuserID" },
+          { "asset.from": "testuserID" },
+          { "asset.to": "testuserID" },
+          { "payment.from": "testuserID" },
+          { "payment.to": "testuserID" }
+        ]
+      },
+      { "status": "completed" }
+    ],
+    "docType": "transfer"
+  },
+  "fields": ["createdBy", "created", "status"],
+  "limit": 10
+}`
+
+const expQueryInTransferAndCompleted = `
+{
+  "selector": {
+    "$or": [
+      { "createdBy": "testuserID" },
+      { "asset.from": "testuserID" },
+      { "asset.to": "testuserID" },
+      { "payment.from": "testuserID" },
+      { "payment.to": "testuserID" }
+    ],
+    "status": "completed",
+    "docType": "transfer"
+  },
+  "limit": 10,
+  "fields": [
+    "createdBy",
+    "created",
+    "status"
+  ]
+}`
+
+const mangoQuery = `
+{
+  "selector": {
+    "docType": "transfer"
+  },
+  "limit": 10,
+  "skip": 20,
+  "sort": [
+    "created",
+    { "status": "desc" }
+  ],
+  "use_index": ["_design/transfers", "by-created"],
+  "conflicts": true,
+  "r": 2,
+  "bookmark": "g1AAAABweJzLYWBgYMpgSmHgKy5JLCrJTq2MT8lPzkzJBYqzGBkZGBgaGRqYmpqaWViCAA",
+  "update": false,
+  "stable": true,
+  "stale": "ok",
+  "execution_stats": true
+}`
+
+const expMangoQueryOnlyCreatedBy = `
+{
+  "selector": {
+    "docType": "wallet",
+    "createdBy": "testuserID"
+  },
+  "sort": [
+    { "created": "asc" },
+    "balance"
+  ],
+  "use_index": "_design/wallets",
+  "bookmark": "g1AAAABweJzLYWBgYMpgSmHgKy5JLCrJTq2MT8lPzkzJBYqzGBkZGBgaGRqYmpqaWViCAA",
+  "update": true
+}`
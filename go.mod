@@ -3,9 +3,10 @@ module github.com/stickypixel/hyperledger/rbac
 go 1.14
 
 require (
-	github.com/golang/protobuf v1.4.2 // indirect
+	github.com/golang/protobuf v1.4.2
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200728190242-9b3ae92d8664
 	github.com/hyperledger/fabric-protos-go v0.0.0-20200728190333-526bfc137380
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
@@ -0,0 +1,197 @@
+package rbac
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/pkg/errors"
+)
+
+// authorizedHistoryIterator wraps a shim.HistoryQueryIteratorInterface and applies the user's history and query
+// rules to each historical version of a key. Versions the user isn't allowed to see are skipped and the rule's
+// field filter is applied to the others.
+type authorizedHistoryIterator struct {
+	shim.HistoryQueryIteratorInterface
+	auth AuthService
+	key  string
+	// prev is the last version which wasn't a delete and peeked is a version read ahead of a delete, either of
+	// which can describe the deleted document.
+	prev   *queryresult.KeyModification
+	peeked *queryresult.KeyModification
+	next   *queryresult.KeyModification
+	err    error
+}
+
+// HasNext returns true if there is another version the user is allowed to see, or an error to return from Next.
+func (it *authorizedHistoryIterator) HasNext() bool {
+	if it.next != nil || it.err != nil {
+		return true
+	}
+
+	for {
+		km, err := it.fetch()
+		if err != nil {
+			it.err = err
+			return true
+		}
+
+		if km == nil {
+			return false
+		}
+
+		var next *queryresult.KeyModification
+
+		if km.IsDelete {
+			next, err = it.filterDelete(km)
+		} else {
+			it.prev = km
+			next, err = it.auth.filterHistory(it.key, km)
+		}
+
+		if err != nil {
+			it.err = err
+			return true
+		}
+
+		if next != nil {
+			it.next = next
+			return true
+		}
+	}
+}
+
+// Next returns the next version the user is allowed to see.
+func (it *authorizedHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, errLedger(errors.New("no more results"))
+	}
+
+	next, err := it.next, it.err
+	it.next, it.err = nil, nil
+
+	return next, err
+}
+
+// fetch returns the next version from the wrapped iterator, or nil if there are no more versions.
+func (it *authorizedHistoryIterator) fetch() (*queryresult.KeyModification, error) {
+	if it.peeked != nil {
+		km := it.peeked
+		it.peeked = nil
+
+		return km, nil
+	}
+
+	if !it.HistoryQueryIteratorInterface.HasNext() {
+		return nil, nil
+	}
+
+	km, err := it.HistoryQueryIteratorInterface.Next()
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return km, nil
+}
+
+// filterDelete returns the delete if the user is allowed to see it.
+// A delete has no value, so it is authorised with the version it deleted. Fabric returns history newest first,
+// so that is the following version; the previous version is used if the delete is the last entry.
+func (it *authorizedHistoryIterator) filterDelete(
+	km *queryresult.KeyModification,
+) (*queryresult.KeyModification, error) {
+	peeked, err := it.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	it.peeked = peeked
+
+	deleted := it.prev
+	if peeked != nil && !peeked.IsDelete {
+		deleted = peeked
+	}
+
+	if deleted == nil {
+		if it.auth.failClosed {
+			return nil, errDocType(it.key)
+		}
+
+		return nil, nil
+	}
+
+	filtered, docType, err := it.auth.filterState(it.key, deleted.Value)
+	if err != nil || filtered == nil {
+		return nil, err
+	}
+
+	if !it.auth.historyRule(docType).IncludeDeletes {
+		return nil, nil
+	}
+
+	return &queryresult.KeyModification{TxId: km.TxId, Timestamp: km.Timestamp, IsDelete: true}, nil
+}
+
+// GetHistoryForKey returns an iterator over the history of the key, with the user's history and query rules
+// applied to each version. If the key exists, the user must be allowed to see the history of its docType.
+// Versions of docTypes whose history the user isn't allowed to see, or which don't match the user's query rule,
+// are skipped. Deletes are only returned if the user's history rule includes them and the user could see the
+// deleted version.
+func (a AuthService) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	current, err := a.stub.GetState(key)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	if current != nil {
+		if docType, err := docTypeOf(key, current); err == nil && !a.historyRule(docType).Allow {
+			return nil, errHistory(docType)
+		}
+	}
+
+	it, err := a.stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	if it == nil {
+		return nil, nil
+	}
+
+	return &authorizedHistoryIterator{HistoryQueryIteratorInterface: it, auth: a, key: key}, nil
+}
+
+// filterHistory applies the user's history and query rules to a historical version.
+// Returns nil, without an error, if the version should be skipped.
+func (a AuthService) filterHistory(
+	key string,
+	km *queryresult.KeyModification,
+) (*queryresult.KeyModification, error) {
+	filtered, docType, err := a.filterState(key, km.Value)
+	if err != nil || filtered == nil {
+		return nil, err
+	}
+
+	if !a.historyRule(docType).Allow {
+		return nil, nil
+	}
+
+	return &queryresult.KeyModification{TxId: km.TxId, Value: filtered, Timestamp: km.Timestamp}, nil
+}
+
+// historyRule returns the user's HistoryRule for a resource.
+// History is allowed if any of the user's roles allow it, and deletes are included if any of those roles include them.
+func (a AuthService) historyRule(resource string) HistoryRule {
+	var rule HistoryRule
+
+	for _, role := range a.userRoles {
+		// Lookup permissions
+		r := a.rolePermissions[role].HistoryPermissions[resource]
+		if !r.Allow {
+			continue
+		}
+
+		rule.Allow = true
+		rule.IncludeDeletes = rule.IncludeDeletes || r.IncludeDeletes
+	}
+
+	return rule
+}
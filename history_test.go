@@ -0,0 +1,196 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+/*
+ *
+ * Create a historyStub which returns a fixed history for each key, as the MockStub has no history
+ *
+ */
+
+type historyStub struct {
+	*shimtest.MockStub
+	history map[string][]*queryresult.KeyModification
+}
+
+func (s *historyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{entries: s.history[key]}, nil
+}
+
+type historyIterator struct {
+	entries []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.entries) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	km := it.entries[0]
+	it.entries = it.entries[1:]
+
+	return km, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
+
+const oldTransferDoc = `{"docType": "transfer", "createdBy": "anotherUserID", "created": 0, "status": "completed"}`
+
+func version(txID, value string) *queryresult.KeyModification {
+	return &queryresult.KeyModification{TxId: txID, Value: []byte(value)}
+}
+
+func deleted(txID string) *queryresult.KeyModification {
+	return &queryresult.KeyModification{TxId: txID, IsDelete: true}
+}
+
+// initHistoryStub returns a stub with the history of keys, newest first, where the newest version is the current state.
+func initHistoryStub() *historyStub {
+	stub := &historyStub{
+		MockStub: initEmptyStub(),
+		history: map[string][]*queryresult.KeyModification{
+			"t1": {
+				version("tx5", doneTransferDoc),
+				deleted("tx4"),
+				version("tx3", oldTransferDoc),
+				deleted("tx2"),
+				version("tx1", otherTransferDoc),
+			},
+			"w1": {
+				version("tx3", walletDoc),
+				deleted("tx2"),
+				version("tx1", otherWalletDoc),
+			},
+			"w2": {
+				deleted("tx2"),
+				version("tx1", walletDoc),
+			},
+		},
+	}
+
+	stub.MockTransactionStart("tx")
+	_ = stub.PutState("t1", []byte(doneTransferDoc))
+	_ = stub.PutState("w1", []byte(walletDoc))
+
+	return stub
+}
+
+func TestGetHistoryForKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		cidRoles string
+		expTxIDs []string
+		expPL    map[string]string
+		msg      string
+	}{
+		{
+			key:      "t1",
+			cidRoles: "auditor",
+			expTxIDs: []string{"tx5", "tx4", "tx3"},
+			expPL: map[string]string{
+				"tx5": `{"createdBy": "anotherUserID", "created": 1, "status": "completed"}`,
+				"tx3": `{"createdBy": "anotherUserID", "created": 0, "status": "completed"}`,
+			},
+			msg: "Should return completed versions with allowed fields and deletes of completed versions",
+		},
+		{
+			key:      "w1",
+			cidRoles: "user",
+			expTxIDs: []string{"tx3"},
+			expPL:    map[string]string{"tx3": walletDoc},
+			msg:      "Should return only versions created by the user, without deletes",
+		},
+		{
+			key:      "w2",
+			cidRoles: "user",
+			expTxIDs: []string{"tx1"},
+			expPL:    map[string]string{"tx1": walletDoc},
+			msg:      "Should return the history of a deleted key",
+		},
+		{
+			key:      "w2",
+			cidRoles: "auditor",
+			expTxIDs: []string{},
+			msg:      "Should return nothing from a deleted key when the user can't see its docType's history",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		appAuth := simpleSetup(t, initHistoryStub(), nil, tt.cidRoles)
+
+		it, err := appAuth.AuthorizedStub().GetHistoryForKey(tt.key)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		txIDs := []string{}
+
+		for it.HasNext() {
+			km, err := it.Next()
+			if !assert.NoError(t, err) {
+				break
+			}
+
+			txIDs = append(txIDs, km.TxId)
+
+			if km.IsDelete {
+				assert.Nil(t, km.Value)
+			} else {
+				assert.JSONEq(t, tt.expPL[km.TxId], string(km.Value))
+			}
+		}
+
+		assert.Equal(t, tt.expTxIDs, txIDs)
+		assert.NoError(t, it.Close())
+	}
+}
+
+func TestGetHistoryForKeyErrors(t *testing.T) {
+	tests := []struct {
+		key      string
+		cidRoles string
+		msg      string
+	}{
+		{
+			key:      "t1",
+			cidRoles: "admin",
+			msg:      "when no role allows history of the docType",
+		},
+		{
+			key:      "w1",
+			cidRoles: "auditor",
+			msg:      "when the role can't see the history of the docType",
+		},
+	}
+
+	for _, tt := range tests {
+		appAuth := simpleSetup(t, initHistoryStub(), nil, tt.cidRoles)
+		it, err := appAuth.GetHistoryForKey(tt.key)
+
+		assert.Nil(t, it)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v",
+				rbac.CodeErrHistory, http.StatusForbidden, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrHistory), e.Code())
+				assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package rbac
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// flatten returns the RolePermissions with every role's inherited permissions merged in to its own.
+// Returns an error if a role inherits from an unknown role or from itself.
+func (rp RolePermissions) flatten() (RolePermissions, error) {
+	flat := make(RolePermissions, len(rp))
+
+	for role := range rp {
+		if _, err := rp.resolve(role, flat, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return flat, nil
+}
+
+// resolve returns the flattened permissions of a role, storing them and those of its ancestors in flat.
+// path is the chain of roles which inherit from role, used to detect cycles.
+func (rp RolePermissions) resolve(role string, flat RolePermissions, path []string) (Permissions, error) {
+	if perms, ok := flat[role]; ok {
+		return perms, nil
+	}
+
+	if contains(path, role) {
+		return Permissions{}, errors.Errorf("role inheritance cycle %v", strings.Join(append(path, role), " -> "))
+	}
+
+	perms, ok := rp[role]
+	if !ok {
+		return Permissions{}, errors.Errorf("role `%v` inherits from unknown role `%v`", path[len(path)-1], role)
+	}
+
+	if len(perms.Inherits) == 0 {
+		flat[role] = perms
+		return perms, nil
+	}
+
+	path = append(path[:len(path):len(path)], role)
+	merged := Permissions{Inherits: perms.Inherits}
+
+	for _, parent := range perms.Inherits {
+		parentPerms, err := rp.resolve(parent, flat, path)
+		if err != nil {
+			return Permissions{}, err
+		}
+
+		merged = mergePermissions(merged, parentPerms)
+	}
+
+	merged = mergePermissions(merged, perms)
+	flat[role] = merged
+
+	return merged, nil
+}
+
+// mergePermissions returns new Permissions with the entries of child overriding those of base.
+// Operations are merged per resource, so a child can override a single operation.
+func mergePermissions(base, child Permissions) Permissions {
+	merged := Permissions{
+		ContractPermissions:     ContractPermissions{},
+		QueryPermissions:        QueryPermissions{},
+		QueryContextPermissions: QueryContextPermissions{},
+		OperationPermissions:    OperationPermissions{},
+		HistoryPermissions:      HistoryPermissions{},
+		CollectionPermissions:   CollectionPermissions{},
+		ContractConditions:      ContractConditions{},
+		Inherits:                base.Inherits,
+	}
+
+	for _, p := range []Permissions{base, child} {
+		for k, v := range p.ContractPermissions {
+			merged.ContractPermissions[k] = v
+		}
+
+		for k, v := range p.QueryPermissions {
+			merged.QueryPermissions[k] = v
+			delete(merged.QueryContextPermissions, k)
+		}
+
+		for k, v := range p.QueryContextPermissions {
+			merged.QueryContextPermissions[k] = v
+		}
+
+		for k, ops := range p.OperationPermissions {
+			if merged.OperationPermissions[k] == nil {
+				merged.OperationPermissions[k] = make(map[Operation]bool, len(ops))
+			}
+
+			for op, allow := range ops {
+				merged.OperationPermissions[k][op] = allow
+			}
+		}
+
+		for k, v := range p.HistoryPermissions {
+			merged.HistoryPermissions[k] = v
+		}
+
+		for k, v := range p.CollectionPermissions {
+			merged.CollectionPermissions[k] = v
+		}
+
+		for k, v := range p.ContractConditions {
+			merged.ContractConditions[k] = v
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,120 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+func getInheritedRolePerms() rbac.RolePermissions {
+	rp := getRolePerms()
+
+	rp["manager"] = rbac.Permissions{
+		Inherits: []string{"user"},
+		ContractPermissions: rbac.ContractPermissions{
+			contractCreateTransfer: true,
+		},
+		QueryPermissions: rbac.QueryPermissions{
+			resourceWallet: allow,
+		},
+		OperationPermissions: rbac.OperationPermissions{
+			resourceWallet: {
+				rbac.OperationDelete: true,
+			},
+		},
+	}
+
+	rp["director"] = rbac.Permissions{
+		Inherits: []string{"manager", "auditor"},
+		ContractPermissions: rbac.ContractPermissions{
+			contractCreateWallet: false,
+		},
+	}
+
+	return rp
+}
+
+func TestRoleInheritance(t *testing.T) {
+	rp := getInheritedRolePerms()
+
+	t.Log("Should inherit the permissions of the parent role, with the role's own entries overriding them")
+
+	appAuth := simpleSetup(t, nil, rp, "manager")
+
+	assert.NoError(t, appAuth.ValidateContractPerms(contractCreateWallet))
+	assert.NoError(t, appAuth.ValidateContractPerms(contractCreateTransfer))
+	assert.NoError(t, appAuth.ValidateOperationPerms(resourceWallet, rbac.OperationUpdate))
+	assert.NoError(t, appAuth.ValidateOperationPerms(resourceWallet, rbac.OperationDelete))
+
+	payload, err := appAuth.ValidateQueryPerms(doctypeQuery(resourceTransfer))
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, expQueryInTransfer, payload)
+	}
+
+	payload, err = appAuth.ValidateQueryPerms(doctypeQuery(resourceWallet))
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, doctypeQuery(resourceWallet), payload)
+	}
+
+	t.Log("Should inherit through several levels and from several parents, with later parents overriding")
+
+	appAuth = simpleSetup(t, nil, rp, "director")
+
+	assert.Error(t, appAuth.ValidateContractPerms(contractCreateWallet))
+	assert.NoError(t, appAuth.ValidateContractPerms(contractCreateTransfer))
+	assert.NoError(t, appAuth.ValidateOperationPerms(resourceWallet, rbac.OperationCreate))
+
+	payload, err = appAuth.ValidateQueryPerms(doctypeQuery(resourceTransfer))
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, expQueryCompleted, payload)
+	}
+
+	_, err = appAuth.ValidateQueryPerms(doctypeQuery(resourceWallet))
+	assert.Error(t, err)
+
+	t.Log("Should not change the given RolePermissions")
+
+	assert.Len(t, rp["manager"].ContractPermissions, 1)
+	assert.Len(t, rp["director"].QueryPermissions, 0)
+}
+
+func TestRoleInheritanceErrors(t *testing.T) {
+	tests := []struct {
+		rp  rbac.RolePermissions
+		msg string
+	}{
+		{
+			rp:  rbac.RolePermissions{"user": {Inherits: []string{"user"}}},
+			msg: "when a role inherits from itself",
+		},
+		{
+			rp: rbac.RolePermissions{
+				"user":    {Inherits: []string{"admin"}},
+				"manager": {Inherits: []string{"user"}},
+				"admin":   {Inherits: []string{"manager"}},
+			},
+			msg: "when roles inherit from each other",
+		},
+		{
+			rp:  rbac.RolePermissions{"user": {Inherits: []string{"guest"}}},
+			msg: "when a role inherits from an unknown role",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := rbac.New(initEmptyStub(), newMockCID(identity{roles: "user"}), tt.rp, "roles")
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v",
+				rbac.CodeErrConfig, http.StatusInternalServerError, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrConfig), e.Code())
+				assert.Equal(t, int32(http.StatusInternalServerError), e.StatusCode())
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package rbac
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+// authorizedIterator wraps a shim.StateQueryIteratorInterface and applies the user's query rules to each document.
+// Documents the user isn't allowed to see are skipped and the rule's field filter is applied to the others.
+type authorizedIterator struct {
+	shim.StateQueryIteratorInterface
+	auth AuthService
+	next *queryresult.KV
+	err  error
+}
+
+// HasNext returns true if there is another document the user is allowed to see, or an error to return from Next.
+func (it *authorizedIterator) HasNext() bool {
+	if it.next != nil || it.err != nil {
+		return true
+	}
+
+	for it.StateQueryIteratorInterface.HasNext() {
+		kv, err := it.StateQueryIteratorInterface.Next()
+		if err != nil {
+			it.err = errLedger(err)
+			return true
+		}
+
+		value, _, err := it.auth.filterState(kv.Key, kv.Value)
+		if err != nil {
+			it.err = err
+			return true
+		}
+
+		if value != nil {
+			it.next = &queryresult.KV{Namespace: kv.Namespace, Key: kv.Key, Value: value}
+			return true
+		}
+	}
+
+	return false
+}
+
+// Next returns the next document the user is allowed to see.
+func (it *authorizedIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errLedger(errors.New("no more results"))
+	}
+
+	next, err := it.next, it.err
+	it.next, it.err = nil, nil
+
+	return next, err
+}
+
+// GetStateByRange returns an iterator over the documents in the key range, with the user's query rules applied.
+func (a AuthService) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	it, err := a.stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return a.authorizeIterator(it), nil
+}
+
+// GetStateByRangeWithPagination returns an iterator over a page of the documents in the key range, with the
+// user's query rules applied. The metadata describes the page before documents were skipped.
+func (a AuthService) GetStateByRangeWithPagination(
+	startKey, endKey string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	it, md, err := a.stub.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, errLedger(err)
+	}
+
+	return a.authorizeIterator(it), md, nil
+}
+
+// GetStateByPartialCompositeKey returns an iterator over the documents with the partial composite key, with the
+// user's query rules applied.
+func (a AuthService) GetStateByPartialCompositeKey(
+	objectType string,
+	keys []string,
+) (shim.StateQueryIteratorInterface, error) {
+	it, err := a.stub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return a.authorizeIterator(it), nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination returns an iterator over a page of the documents with the partial
+// composite key, with the user's query rules applied. The metadata describes the page before documents were skipped.
+func (a AuthService) GetStateByPartialCompositeKeyWithPagination(
+	objectType string,
+	keys []string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	it, md, err := a.stub.GetStateByPartialCompositeKeyWithPagination(objectType, keys, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, errLedger(err)
+	}
+
+	return a.authorizeIterator(it), md, nil
+}
+
+// authorizeIterator wraps the iterator so the user's query rules are applied to each document.
+func (a AuthService) authorizeIterator(it shim.StateQueryIteratorInterface) shim.StateQueryIteratorInterface {
+	if it == nil {
+		return nil
+	}
+
+	return &authorizedIterator{StateQueryIteratorInterface: it, auth: a}
+}
+
+// filterState applies the user's query rules to a document returned by an iterator. Returns nil, without an error,
+// if the document should be skipped. Documents without a docType, or with a docType which isn't in any role's
+// QueryPermissions, are skipped unless the AuthService fails closed, in which case they are an error.
+func (a AuthService) filterState(key string, value []byte) ([]byte, string, error) {
+	filtered, docType, err := a.filterDocument(key, value)
+
+	if e, ok := err.(authError); ok && e.code == CodeErrDocType && !a.failClosed {
+		return nil, "", nil
+	}
+
+	if err != nil {
+		return nil, docType, err
+	}
+
+	if filtered == nil && a.failClosed && !a.knownDocType(docType) {
+		return nil, docType, errUnknownDocType(docType)
+	}
+
+	return filtered, docType, nil
+}
+
+// knownDocType returns whether any role has a query rule for the docType.
+func (a AuthService) knownDocType(docType string) bool {
+	for _, perms := range a.rolePermissions {
+		if _, ok := perms.queryRuleFunc(docType); ok {
+			return true
+		}
+	}
+
+	return false
+}
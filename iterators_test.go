@@ -0,0 +1,252 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+/*
+ *
+ * Create a pageStub which implements pagination over range queries, as the MockStub returns nothing
+ *
+ */
+
+type pageStub struct {
+	*shimtest.MockStub
+}
+
+func (s *pageStub) GetStateByRangeWithPagination(
+	startKey, endKey string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	it, err := s.GetStateByRange(startKey, endKey)
+
+	return it, &peer.QueryResponseMetadata{FetchedRecordsCount: pageSize}, err
+}
+
+const (
+	noDocTypeDoc = `{"createdBy": "testuserID"}`
+	unknownDoc   = `{"docType": "gadget", "createdBy": "testuserID"}`
+)
+
+func rangeState() map[string]string {
+	return map[string]string{
+		"a1": walletDoc,
+		"a2": otherWalletDoc,
+		"a3": toTransferDoc,
+		"a4": otherTransferDoc,
+		"a5": assetDoc,
+		"a6": doneTransferDoc,
+		"b1": noDocTypeDoc,
+		"b2": unknownDoc,
+	}
+}
+
+func initRangeStub(state map[string]string) *shimtest.MockStub {
+	stub := initEmptyStub()
+	stub.MockTransactionStart("tx")
+
+	for k, v := range state {
+		_ = stub.PutState(k, []byte(v))
+	}
+
+	return stub
+}
+
+// collect returns the results of an iterator by key, or the first error returned.
+func collect(it shim.StateQueryIteratorInterface) (map[string]string, error) {
+	res := map[string]string{}
+
+	defer it.Close()
+
+	for it.HasNext() {
+		kv, err := it.Next()
+		if err != nil {
+			return res, err
+		}
+
+		res[kv.Key] = string(kv.Value)
+	}
+
+	return res, nil
+}
+
+func TestGetStateByRange(t *testing.T) {
+	tests := []struct {
+		cidRoles string
+		opts     []rbac.Option
+		exp      map[string]string
+		msg      string
+	}{
+		{
+			cidRoles: "user",
+			exp:      map[string]string{"a1": walletDoc, "a3": toTransferDoc},
+			msg:      "Should return only the user's wallets and transfers to the user",
+		},
+		{
+			cidRoles: "admin",
+			exp: map[string]string{
+				"a3": toTransferDoc,
+				"a4": otherTransferDoc,
+				"a5": `{"createdBy": "anotherUserID", "created": 1600000000}`,
+				"a6": doneTransferDoc,
+			},
+			msg: "Should return all transfers and only the allowed fields of assets",
+		},
+		{
+			cidRoles: "auditor",
+			exp:      map[string]string{"a6": `{"createdBy": "anotherUserID", "created": 1, "status": "completed"}`},
+			msg:      "Should return only the allowed fields of completed transfers",
+		},
+		{
+			cidRoles: "user",
+			opts:     []rbac.Option{rbac.WithFailClosed()},
+			exp:      map[string]string{"a1": walletDoc, "a3": toTransferDoc},
+			msg:      "Should skip documents of known docTypes when failing closed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		appAuth := simpleSetup(t, initRangeStub(rangeState()), nil, tt.cidRoles, tt.opts...)
+
+		it, err := appAuth.GetStateByRange("a", "b")
+		if assert.NoError(t, err) {
+			res, err := collect(it)
+			if assert.NoError(t, err) {
+				assert.Len(t, res, len(tt.exp))
+
+				for k, v := range tt.exp {
+					assert.JSONEq(t, v, res[k])
+				}
+			}
+		}
+	}
+
+	t.Log("Should skip documents without a docType or with an unknown docType by default")
+
+	it, err := simpleSetup(t, initRangeStub(rangeState()), nil, "admin").GetStateByRange("b", "c")
+	if assert.NoError(t, err) {
+		res, err := collect(it)
+		assert.NoError(t, err)
+		assert.Empty(t, res)
+	}
+}
+
+func TestGetStateByRangeErrors(t *testing.T) {
+	tests := []struct {
+		startKey string
+		endKey   string
+		expSC    int32
+		expC     int32
+		msg      string
+	}{
+		{
+			startKey: "b1",
+			endKey:   "b2",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocType,
+			msg:      "when a document has no docType",
+		},
+		{
+			startKey: "b2",
+			endKey:   "b3",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrUnknownDocType,
+			msg:      "when no role has query permissions for a docType",
+		},
+	}
+
+	for _, tt := range tests {
+		appAuth := simpleSetup(t, initRangeStub(rangeState()), nil, "admin", rbac.WithFailClosed())
+
+		it, err := appAuth.GetStateByRange(tt.startKey, tt.endKey)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		_, err = collect(it)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v", tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
+
+func TestGetStateByRangeWithPagination(t *testing.T) {
+	t.Log("Should filter a page of results and return the page metadata")
+
+	stub := &pageStub{initRangeStub(rangeState())}
+	appAuth := simpleSetup(t, stub, nil, "user")
+
+	it, md, err := appAuth.AuthorizedStub().GetStateByRangeWithPagination("a", "b", 10, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int32(10), md.FetchedRecordsCount)
+
+		res, err := collect(it)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]string{"a1": walletDoc, "a3": toTransferDoc}, res)
+		}
+	}
+}
+
+func TestGetStateByPartialCompositeKey(t *testing.T) {
+	stub := initEmptyStub()
+	stub.MockTransactionStart("tx")
+
+	state := map[string]string{
+		"testuserID":    walletDoc,
+		"anotherUserID": otherWalletDoc,
+	}
+
+	for owner, doc := range state {
+		key, err := stub.CreateCompositeKey("owner~wallet", []string{owner})
+		assert.NoError(t, err)
+		assert.NoError(t, stub.PutState(key, []byte(doc)))
+	}
+
+	t.Log("Should return only the user's wallets from a composite key index")
+
+	appAuth := simpleSetup(t, stub, nil, "user")
+	payload, err := appAuth.WithContractAuth(
+		contractQueryLedger,
+		[]string{},
+		func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+			it, err := stub.GetStateByPartialCompositeKey("owner~wallet", []string{})
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := collect(it)
+			if err != nil {
+				return nil, err
+			}
+
+			assert.Len(t, res, 1)
+
+			for _, v := range res {
+				return []byte(v), nil
+			}
+
+			return nil, nil
+		},
+	)
+
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, walletDoc, string(payload))
+	}
+}
@@ -0,0 +1,595 @@
+package rbac
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fieldStatus describes the result of looking up a field path in a document.
+type fieldStatus int
+
+const (
+	fieldFound fieldStatus = iota
+	// fieldNotFound means a field in the path doesn't exist.
+	fieldNotFound
+	// fieldBadPath means the path goes through a value which isn't an object or array.
+	fieldBadPath
+)
+
+// Matches returns whether the decoded JSON document matches the selector, following CouchDB's Mango semantics,
+// so that a selector can be enforced on documents which weren't returned by a CouchDB query.
+// Values are ordered with CouchDB's collation (null, false, true, numbers, strings, arrays then objects),
+// except that strings are compared by code point rather than with ICU collation and object keys are compared
+// in sorted order. An error is returned if the selector has an invalid or unsupported operator.
+func (s CDBSelector) Matches(doc interface{}) (bool, error) {
+	sel, _ := normalizeJSON(map[string]interface{}(s)).(map[string]interface{})
+
+	if err := validateSelector(sel); err != nil {
+		return false, err
+	}
+
+	return matchObject(sel, normalizeJSON(doc), fieldFound)
+}
+
+// validateSelector returns an error if any operator in the selector is invalid or unsupported. The whole selector
+// is validated before it is evaluated, as evaluation stops at the first condition which doesn't match.
+func validateSelector(sel map[string]interface{}) error {
+	for _, k := range sortedKeys(sel) {
+		if err := validateCondition(k, sel[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateCondition validates a single key of a selector object, which is either an operator or a field.
+func validateCondition(k string, cond interface{}) error {
+	if !strings.HasPrefix(k, "$") {
+		if m, ok := cond.(map[string]interface{}); ok {
+			return validateSelector(m)
+		}
+
+		return nil
+	}
+
+	switch k {
+	case "$and", "$or", "$nor":
+		args, ok := cond.([]interface{})
+		if !ok {
+			return errSelector(k)
+		}
+
+		for _, a := range args {
+			sel, ok := a.(map[string]interface{})
+			if !ok {
+				return errSelector(k)
+			}
+
+			if err := validateSelector(sel); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "$not", "$elemMatch", "$allMatch":
+		sel, ok := cond.(map[string]interface{})
+		if !ok {
+			return errSelector(k)
+		}
+
+		return validateSelector(sel)
+	case "$exists":
+		if _, ok := cond.(bool); !ok {
+			return errSelector(k)
+		}
+
+		return nil
+	default:
+		// Evaluating a value operator checks its argument, whatever the value
+		_, err := matchValue(k, cond, nil)
+
+		return err
+	}
+}
+
+// matchObject returns whether the value matches every condition in the selector object. Keys which are operators
+// apply to the value itself, any other key is a condition on a field of the value.
+func matchObject(sel map[string]interface{}, value interface{}, status fieldStatus) (bool, error) {
+	for k, cond := range sel {
+		var (
+			match bool
+			err   error
+		)
+
+		if strings.HasPrefix(k, "$") {
+			match, err = matchOperator(k, cond, value, status)
+		} else {
+			var child interface{}
+
+			childStatus := status
+			if status == fieldFound {
+				child, childStatus = getField(value, k)
+			}
+
+			match, err = matchCondition(cond, child, childStatus)
+		}
+
+		if err != nil || !match {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// matchCondition returns whether the value of a field matches the condition. An object condition contains
+// operators on the field and conditions on its sub-fields, anything else is an implicit `$eq`.
+func matchCondition(cond, value interface{}, status fieldStatus) (bool, error) {
+	if m, ok := cond.(map[string]interface{}); ok {
+		return matchObject(m, value, status)
+	}
+
+	return status == fieldFound && compareJSON(value, cond) == 0, nil
+}
+
+// matchOperator returns whether the value matches a single operator condition.
+// As in CouchDB, a field which doesn't exist only matches `$exists: false`, or the negation of a condition.
+func matchOperator(op string, arg, value interface{}, status fieldStatus) (bool, error) {
+	switch op {
+	case "$and", "$or", "$nor":
+		return matchCombination(op, arg, value, status)
+	case "$not":
+		sel, ok := arg.(map[string]interface{})
+		if !ok {
+			return false, errSelector(op)
+		}
+
+		match, err := matchObject(sel, value, status)
+
+		return !match && err == nil, err
+	case "$exists":
+		want, ok := arg.(bool)
+		if !ok {
+			return false, errSelector(op)
+		}
+
+		return status != fieldBadPath && (status == fieldFound) == want, nil
+	}
+
+	// Evaluate the operator even if the field doesn't exist, so an invalid argument is always an error
+	match, err := matchValue(op, arg, value)
+
+	return status == fieldFound && match, err
+}
+
+// matchCombination evaluates the `$and`, `$or` and `$nor` operators. As in CouchDB, an empty array always matches.
+func matchCombination(op string, arg, value interface{}, status fieldStatus) (bool, error) {
+	args, ok := arg.([]interface{})
+	if !ok {
+		return false, errSelector(op)
+	}
+
+	if len(args) == 0 {
+		return true, nil
+	}
+
+	for _, a := range args {
+		sel, ok := a.(map[string]interface{})
+		if !ok {
+			return false, errSelector(op)
+		}
+
+		match, err := matchObject(sel, value, status)
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case op == "$and" && !match:
+			return false, nil
+		case op == "$or" && match:
+			return true, nil
+		case op == "$nor" && match:
+			return false, nil
+		}
+	}
+
+	return op != "$or", nil
+}
+
+// matchValue returns whether the value matches a condition operator.
+func matchValue(op string, arg, value interface{}) (bool, error) {
+	switch op {
+	case "$eq":
+		return compareJSON(value, arg) == 0, nil
+	case "$ne":
+		return compareJSON(value, arg) != 0, nil
+	case "$lt":
+		return compareJSON(value, arg) < 0, nil
+	case "$lte":
+		return compareJSON(value, arg) <= 0, nil
+	case "$gt":
+		return compareJSON(value, arg) > 0, nil
+	case "$gte":
+		return compareJSON(value, arg) >= 0, nil
+	case "$in", "$nin":
+		args, ok := arg.([]interface{})
+		if !ok {
+			return false, errSelector(op)
+		}
+
+		return anyEqual(value, args) == (op == "$in"), nil
+	case "$all":
+		args, ok := arg.([]interface{})
+		if !ok {
+			return false, errSelector(op)
+		}
+
+		return matchAll(value, args), nil
+	case "$size":
+		size, ok := toInt(arg)
+		if !ok || size < 0 {
+			return false, errSelector(op)
+		}
+
+		values, ok := value.([]interface{})
+
+		return ok && int64(len(values)) == size, nil
+	case "$type":
+		t, ok := arg.(string)
+		if !ok || !validJSONType(t) {
+			return false, errSelector(op)
+		}
+
+		return jsonType(value) == t, nil
+	case "$mod":
+		return matchMod(arg, value)
+	case "$regex":
+		return matchRegex(arg, value)
+	case "$elemMatch", "$allMatch":
+		return matchElements(op, arg, value)
+	default:
+		return false, errSelector(op)
+	}
+}
+
+// anyEqual returns whether the value, or any element of the value if it is an array, equals any of the args.
+func anyEqual(value interface{}, args []interface{}) bool {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+
+	for _, v := range values {
+		for _, arg := range args {
+			if compareJSON(v, arg) == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchAll returns whether the value is an array containing all of the args. No args never matches.
+func matchAll(value interface{}, args []interface{}) bool {
+	values, ok := value.([]interface{})
+	if !ok || len(args) == 0 {
+		return false
+	}
+
+	for _, arg := range args {
+		found := false
+
+		for _, v := range values {
+			if compareJSON(v, arg) == 0 {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchMod evaluates `$mod`, which takes a [divisor, remainder] argument and only matches integers.
+func matchMod(arg, value interface{}) (bool, error) {
+	args, ok := arg.([]interface{})
+	if !ok || len(args) != 2 {
+		return false, errSelector("$mod")
+	}
+
+	divisor, ok := toInt(args[0])
+	if !ok || divisor == 0 {
+		return false, errSelector("$mod")
+	}
+
+	remainder, ok := toInt(args[1])
+	if !ok {
+		return false, errSelector("$mod")
+	}
+
+	n, ok := toInt(value)
+
+	return ok && n%divisor == remainder, nil
+}
+
+// matchRegex evaluates `$regex`, which only matches strings.
+// Go's regular expression syntax is used, which is a subset of the PCRE syntax used by CouchDB.
+func matchRegex(arg, value interface{}) (bool, error) {
+	pattern, ok := arg.(string)
+	if !ok {
+		return false, errSelector("$regex")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, errSelector("$regex")
+	}
+
+	s, ok := value.(string)
+
+	return ok && re.MatchString(s), nil
+}
+
+// matchElements evaluates `$elemMatch`, which matches arrays with any matching element,
+// and `$allMatch`, which matches non-empty arrays where every element matches.
+func matchElements(op string, arg, value interface{}) (bool, error) {
+	sel, ok := arg.(map[string]interface{})
+	if !ok {
+		return false, errSelector(op)
+	}
+
+	values, ok := value.([]interface{})
+	if !ok || len(values) == 0 {
+		return false, nil
+	}
+
+	for _, v := range values {
+		match, err := matchObject(sel, v, fieldFound)
+		if err != nil {
+			return false, err
+		}
+
+		if op == "$elemMatch" && match {
+			return true, nil
+		}
+
+		if op == "$allMatch" && !match {
+			return false, nil
+		}
+	}
+
+	return op == "$allMatch", nil
+}
+
+// getField returns the value at the field path within the value. Numeric path segments index in to arrays.
+func getField(value interface{}, path string) (interface{}, fieldStatus) {
+	for _, key := range splitPath(path) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[key]
+			if !ok {
+				return nil, fieldNotFound
+			}
+
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 {
+				return nil, fieldBadPath
+			}
+
+			if i >= len(v) {
+				return nil, fieldNotFound
+			}
+
+			value = v[i]
+		default:
+			return nil, fieldBadPath
+		}
+	}
+
+	return value, fieldFound
+}
+
+// splitPath splits a field path on dots, except for dots escaped with a backslash.
+func splitPath(path string) []string {
+	var (
+		keys []string
+		key  strings.Builder
+	)
+
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			key.WriteByte('.')
+			i++
+		case path[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+
+	return append(keys, key.String())
+}
+
+// compareJSON compares two decoded JSON values using CouchDB's collation order, returning -1, 0 or 1.
+func compareJSON(a, b interface{}) int {
+	if ra, rb := jsonRank(a), jsonRank(b); ra != rb {
+		return compareInts(ra, rb)
+	}
+
+	switch av := a.(type) {
+	case float64:
+		bv := b.(float64)
+
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		default:
+			return 0
+		}
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := compareJSON(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+
+		return compareInts(len(av), len(bv))
+	case map[string]interface{}:
+		bv := b.(map[string]interface{})
+		ak, bk := sortedKeys(av), sortedKeys(bv)
+
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := strings.Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+
+			if c := compareJSON(av[ak[i]], bv[bk[i]]); c != 0 {
+				return c
+			}
+		}
+
+		return compareInts(len(ak), len(bk))
+	default:
+		// null, false and true each have their own rank
+		return 0
+	}
+}
+
+// jsonRank returns the position of the value's type in CouchDB's collation order.
+func jsonRank(v interface{}) int {
+	switch val := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if !val {
+			return 1
+		}
+
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// jsonType returns the Mango `$type` name of the value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// validJSONType returns whether t is a valid Mango `$type` name.
+func validJSONType(t string) bool {
+	switch t {
+	case "null", "boolean", "number", "string", "array", "object":
+		return true
+	default:
+		return false
+	}
+}
+
+// toInt returns the value as an integer if it is a number without a fractional part.
+func toInt(v interface{}) (int64, bool) {
+	f, ok := v.(float64)
+	if !ok || f != math.Trunc(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+
+	return int64(f), true
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// normalizeJSON converts a value built in Go, which may contain CDBSelectors, typed slices and integers,
+// in to the types produced by decoding JSON.
+func normalizeJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, bool, string, float64:
+		return val
+	case map[string]interface{}:
+		n := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			n[k] = normalizeJSON(child)
+		}
+
+		return n
+	case []interface{}:
+		n := make([]interface{}, len(val))
+		for i, child := range val {
+			n[i] = normalizeJSON(child)
+		}
+
+		return n
+	default:
+		// Round trip anything else, e.g. ints, CDBSelectors or []string, through JSON
+		b, err := json.Marshal(val)
+		if err != nil {
+			return val
+		}
+
+		var n interface{}
+		if err := json.Unmarshal(b, &n); err != nil {
+			return val
+		}
+
+		return n
+	}
+}
@@ -0,0 +1,284 @@
+package rbac_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+const matchDoc = `
+{
+  "docType": "transfer",
+  "amount": 100,
+  "fee": 2.5,
+  "status": "completed",
+  "approved": true,
+  "cancelled": false,
+  "note": null,
+  "tags": ["urgent", "intl"],
+  "scores": [3, 7, 9],
+  "empty": [],
+  "asset": { "from": "alice", "to": "bob", "meta": { "id": "a1" } },
+  "payments": [
+    { "amount": 50, "to": "bob" },
+    { "amount": 150, "to": "carol" }
+  ],
+  "matrix": [[1, 2], [3, 4]],
+  "dotted.key": "escaped"
+}`
+
+func TestSelectorMatches(t *testing.T) {
+	tests := []struct {
+		sel   string
+		match bool
+	}{
+		// Implicit equality and $eq
+		{sel: `{}`, match: true},
+		{sel: `{"status": "completed"}`, match: true},
+		{sel: `{"status": "pending"}`, match: false},
+		{sel: `{"amount": 100}`, match: true},
+		{sel: `{"amount": 100.0}`, match: true},
+		{sel: `{"amount": "100"}`, match: false},
+		{sel: `{"note": null}`, match: true},
+		{sel: `{"approved": true}`, match: true},
+		{sel: `{"cancelled": false}`, match: true},
+		{sel: `{"tags": ["urgent", "intl"]}`, match: true},
+		{sel: `{"tags": ["intl", "urgent"]}`, match: false},
+		{sel: `{"tags": "urgent"}`, match: false},
+		{sel: `{"status": {"$eq": "completed"}}`, match: true},
+		{sel: `{"asset": {"$eq": {"to": "bob", "from": "alice", "meta": {"id": "a1"}}}}`, match: true},
+		{sel: `{"asset": {"$eq": {"from": "alice"}}}`, match: false},
+
+		// Dotted paths, nested objects and array indexes
+		{sel: `{"asset.from": "alice"}`, match: true},
+		{sel: `{"asset.meta.id": "a1"}`, match: true},
+		{sel: `{"asset": {"from": "alice"}}`, match: true},
+		{sel: `{"asset": {"meta": {"id": {"$eq": "a1"}}}}`, match: true},
+		{sel: `{"asset": {"from": "bob"}}`, match: false},
+		{sel: `{"payments.1.to": "carol"}`, match: true},
+		{sel: `{"payments.0.to": "carol"}`, match: false},
+		{sel: `{"matrix.1.0": 3}`, match: true},
+		{sel: `{"dotted\\.key": "escaped"}`, match: true},
+		{sel: `{"dotted.key": "escaped"}`, match: false},
+
+		// $ne
+		{sel: `{"status": {"$ne": "pending"}}`, match: true},
+		{sel: `{"status": {"$ne": "completed"}}`, match: false},
+		{sel: `{"missing": {"$ne": "anything"}}`, match: false},
+
+		// $gt, $gte, $lt and $lte, including CouchDB collation across types
+		{sel: `{"amount": {"$gt": 99}}`, match: true},
+		{sel: `{"amount": {"$gt": 100}}`, match: false},
+		{sel: `{"amount": {"$gte": 100}}`, match: true},
+		{sel: `{"amount": {"$lt": 100}}`, match: false},
+		{sel: `{"amount": {"$lte": 100}}`, match: true},
+		{sel: `{"amount": {"$gt": 50, "$lt": 150}}`, match: true},
+		{sel: `{"amount": {"$gt": 50, "$lt": 60}}`, match: false},
+		{sel: `{"fee": {"$lt": 3}}`, match: true},
+		{sel: `{"status": {"$gt": "b", "$lt": "d"}}`, match: true},
+		{sel: `{"status": {"$gt": 1000000}}`, match: true},
+		{sel: `{"amount": {"$lt": "0"}}`, match: true},
+		{sel: `{"amount": {"$gt": true}}`, match: true},
+		{sel: `{"cancelled": {"$lt": true}}`, match: true},
+		{sel: `{"note": {"$lt": false}}`, match: true},
+		{sel: `{"tags": {"$gt": "zzz"}}`, match: true},
+		{sel: `{"tags": {"$lt": {}}}`, match: true},
+		{sel: `{"tags": {"$gt": ["urgent"]}}`, match: true},
+		{sel: `{"tags": {"$lt": ["urgent", "intl", "x"]}}`, match: true},
+		{sel: `{"asset": {"$gt": {"from": "alice"}}}`, match: true},
+		{sel: `{"missing": {"$lt": 1}}`, match: false},
+
+		// $in and $nin, which also match elements of array fields
+		{sel: `{"status": {"$in": ["pending", "completed"]}}`, match: true},
+		{sel: `{"status": {"$in": ["pending", "failed"]}}`, match: false},
+		{sel: `{"status": {"$in": []}}`, match: false},
+		{sel: `{"tags": {"$in": ["intl"]}}`, match: true},
+		{sel: `{"tags": {"$in": ["domestic"]}}`, match: false},
+		{sel: `{"status": {"$nin": ["pending", "failed"]}}`, match: true},
+		{sel: `{"status": {"$nin": ["completed"]}}`, match: false},
+		{sel: `{"tags": {"$nin": ["urgent"]}}`, match: false},
+		{sel: `{"missing": {"$nin": ["anything"]}}`, match: false},
+
+		// $exists, including paths through values which aren't objects
+		{sel: `{"status": {"$exists": true}}`, match: true},
+		{sel: `{"status": {"$exists": false}}`, match: false},
+		{sel: `{"note": {"$exists": true}}`, match: true},
+		{sel: `{"missing": {"$exists": false}}`, match: true},
+		{sel: `{"missing": {"$exists": true}}`, match: false},
+		{sel: `{"missing.child": {"$exists": false}}`, match: true},
+		{sel: `{"asset.missing": {"$exists": false}}`, match: true},
+		{sel: `{"status.child": {"$exists": false}}`, match: false},
+		{sel: `{"payments.5": {"$exists": false}}`, match: true},
+		{sel: `{"payments.x": {"$exists": false}}`, match: false},
+		{sel: `{"asset": {"missing": {"$exists": false}}}`, match: true},
+
+		// $and, $or, $nor and $not
+		{sel: `{"$and": [{"status": "completed"}, {"amount": 100}]}`, match: true},
+		{sel: `{"$and": [{"status": "completed"}, {"amount": 1}]}`, match: false},
+		{sel: `{"$and": []}`, match: true},
+		{sel: `{"$or": [{"status": "pending"}, {"amount": 100}]}`, match: true},
+		{sel: `{"$or": [{"status": "pending"}, {"amount": 1}]}`, match: false},
+		{sel: `{"$or": []}`, match: true},
+		{sel: `{"$nor": [{"status": "pending"}, {"amount": 1}]}`, match: true},
+		{sel: `{"$nor": [{"status": "pending"}, {"amount": 100}]}`, match: false},
+		{sel: `{"$not": {"status": "pending"}}`, match: true},
+		{sel: `{"$not": {"status": "completed"}}`, match: false},
+		{sel: `{"$not": {"missing": "anything"}}`, match: true},
+		{sel: `{"amount": {"$not": {"$gt": 200}}}`, match: true},
+		{sel: `{"amount": {"$not": {"$gt": 50}}}`, match: false},
+		{sel: `{"missing": {"$not": {"$eq": 1}}}`, match: true},
+		{sel: `{"amount": {"$or": [{"$lt": 10}, {"$gt": 90}]}}`, match: true},
+		{sel: `{"amount": {"$or": [{"$lt": 10}, {"$gt": 900}]}}`, match: false},
+		{sel: `{"asset": {"$and": [{"from": "alice"}, {"to": "bob"}]}}`, match: true},
+		{sel: `{"$or": [{"asset.from": "bob"}, {"$and": [{"asset.to": "bob"}, {"status": "completed"}]}]}`, match: true},
+
+		// $elemMatch and $allMatch
+		{sel: `{"payments": {"$elemMatch": {"amount": {"$gt": 100}, "to": "carol"}}}`, match: true},
+		{sel: `{"payments": {"$elemMatch": {"amount": {"$gt": 100}, "to": "bob"}}}`, match: false},
+		{sel: `{"tags": {"$elemMatch": {"$eq": "intl"}}}`, match: true},
+		{sel: `{"scores": {"$elemMatch": {"$gt": 8}}}`, match: true},
+		{sel: `{"scores": {"$elemMatch": {"$gt": 9}}}`, match: false},
+		{sel: `{"empty": {"$elemMatch": {"$exists": true}}}`, match: false},
+		{sel: `{"status": {"$elemMatch": {"$eq": "completed"}}}`, match: false},
+		{sel: `{"matrix": {"$elemMatch": {"$elemMatch": {"$eq": 4}}}}`, match: true},
+		{sel: `{"scores": {"$allMatch": {"$gt": 2}}}`, match: true},
+		{sel: `{"scores": {"$allMatch": {"$gt": 3}}}`, match: false},
+		{sel: `{"payments": {"$allMatch": {"amount": {"$gte": 50}}}}`, match: true},
+		{sel: `{"empty": {"$allMatch": {"$exists": true}}}`, match: false},
+		{sel: `{"status": {"$allMatch": {"$eq": "completed"}}}`, match: false},
+
+		// $regex
+		{sel: `{"status": {"$regex": "^comp"}}`, match: true},
+		{sel: `{"status": {"$regex": "let"}}`, match: true},
+		{sel: `{"status": {"$regex": "^pend"}}`, match: false},
+		{sel: `{"status": {"$regex": "(?i)^COMPLETED$"}}`, match: true},
+		{sel: `{"amount": {"$regex": "100"}}`, match: false},
+		{sel: `{"missing": {"$regex": ".*"}}`, match: false},
+
+		// $size
+		{sel: `{"tags": {"$size": 2}}`, match: true},
+		{sel: `{"tags": {"$size": 3}}`, match: false},
+		{sel: `{"empty": {"$size": 0}}`, match: true},
+		{sel: `{"status": {"$size": 9}}`, match: false},
+
+		// $all, $type and $mod
+		{sel: `{"tags": {"$all": ["intl", "urgent"]}}`, match: true},
+		{sel: `{"tags": {"$all": ["intl", "domestic"]}}`, match: false},
+		{sel: `{"tags": {"$all": []}}`, match: false},
+		{sel: `{"status": {"$all": ["completed"]}}`, match: false},
+		{sel: `{"status": {"$type": "string"}}`, match: true},
+		{sel: `{"amount": {"$type": "number"}}`, match: true},
+		{sel: `{"note": {"$type": "null"}}`, match: true},
+		{sel: `{"approved": {"$type": "boolean"}}`, match: true},
+		{sel: `{"tags": {"$type": "array"}}`, match: true},
+		{sel: `{"asset": {"$type": "object"}}`, match: true},
+		{sel: `{"asset": {"$type": "array"}}`, match: false},
+		{sel: `{"amount": {"$mod": [30, 10]}}`, match: true},
+		{sel: `{"amount": {"$mod": [30, 0]}}`, match: false},
+		{sel: `{"fee": {"$mod": [2, 0]}}`, match: false},
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(matchDoc), &doc); err != nil {
+		t.Fatalf("Unmarshalling doc failed unexpectedly: %v", err)
+	}
+
+	for _, tt := range tests {
+		var sel rbac.CDBSelector
+		if err := json.Unmarshal([]byte(tt.sel), &sel); err != nil {
+			t.Fatalf("Unmarshalling selector %v failed unexpectedly: %v", tt.sel, err)
+		}
+
+		match, err := sel.Matches(doc)
+		if assert.NoError(t, err, tt.sel) {
+			assert.Equal(t, tt.match, match, "selector %v should match: %v", tt.sel, tt.match)
+		}
+	}
+}
+
+func TestSelectorMatchesGoValues(t *testing.T) {
+	t.Log("Should match selectors built in Go against documents built in Go")
+
+	doc := map[string]interface{}{
+		"createdBy": "testuserID",
+		"amount":    100,
+		"asset":     map[string]interface{}{"from": "testuserID"},
+	}
+
+	inTransfer := rbac.CDBSelector{
+		"$or": []rbac.CDBSelector{
+			{"createdBy": "anotherUserID"},
+			{"asset.from": "testuserID"},
+		},
+		"amount": rbac.CDBSelector{"$gte": 100, "$in": []int{50, 100}},
+	}
+
+	match, err := inTransfer.Matches(doc)
+	assert.NoError(t, err)
+	assert.True(t, match)
+
+	var nilSel rbac.CDBSelector
+
+	match, err = nilSel.Matches(doc)
+	assert.NoError(t, err)
+	assert.True(t, match)
+}
+
+func TestSelectorMatchesErrors(t *testing.T) {
+	tests := []string{
+		`{"status": {"$unknown": 1}}`,
+		`{"$where": "function() {}"}`,
+		`{"$and": {"status": "completed"}}`,
+		`{"$or": ["completed"]}`,
+		`{"$not": "completed"}`,
+		`{"status": {"$exists": "yes"}}`,
+		`{"status": {"$in": "completed"}}`,
+		`{"status": {"$nin": "completed"}}`,
+		`{"tags": {"$all": "urgent"}}`,
+		`{"tags": {"$size": "2"}}`,
+		`{"tags": {"$size": 1.5}}`,
+		`{"tags": {"$type": "list"}}`,
+		`{"amount": {"$mod": [0, 1]}}`,
+		`{"amount": {"$mod": [2]}}`,
+		`{"status": {"$regex": "("}}`,
+		`{"status": {"$regex": 1}}`,
+		`{"payments": {"$elemMatch": [1]}}`,
+		`{"missing": {"$in": "completed"}}`,
+		`{"status": "pending", "amount": {"$bogus": 1}}`,
+		`{"$or": [{"status": "completed"}, {"amount": {"$bogus": 1}}]}`,
+		`{"$and": [{"status": "pending"}, {"amount": {"$gt": 1, "$bogus": 1}}]}`,
+		`{"missing": {"$elemMatch": {"$bogus": 1}}}`,
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(matchDoc), &doc); err != nil {
+		t.Fatalf("Unmarshalling doc failed unexpectedly: %v", err)
+	}
+
+	for _, s := range tests {
+		var sel rbac.CDBSelector
+		if err := json.Unmarshal([]byte(s), &sel); err != nil {
+			t.Fatalf("Unmarshalling selector %v failed unexpectedly: %v", s, err)
+		}
+
+		// Match several times, as the conditions of a selector object are evaluated in map order
+		for i := 0; i < 10; i++ {
+			_, err := sel.Matches(doc)
+			assert.Error(t, err, s)
+		}
+
+		_, err := sel.Matches(doc)
+		if assert.Error(t, err, s) {
+			t.Logf("Should return an error with code %v for selector %v\nerr: %v", rbac.CodeErrSelector, s, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrSelector), e.Code())
+				assert.Equal(t, int32(http.StatusBadRequest), e.StatusCode())
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package rbac
+
+// Option configures optional behaviour of the AuthService when it is passed to New.
+type Option func(a *AuthService)
+
+// WithQueryCombining sets how the QueryRules of a user's roles are combined. Defaults to QueryCombineUnion.
+func WithQueryCombining(c QueryCombining) Option {
+	return func(a *AuthService) {
+		a.queryCombining = c
+	}
+}
+
+// WithFailClosed makes iterators return an error for documents which can't be authorised, rather than skipping them.
+// This applies to documents without a docType and to docTypes which aren't in any role's QueryPermissions.
+func WithFailClosed() Option {
+	return func(a *AuthService) {
+		a.failClosed = true
+	}
+}
+
+// WithPermissionCombining sets how the contract and operation permissions of a user's roles are combined.
+// Defaults to PermitOverrides.
+func WithPermissionCombining(c PermissionCombining) Option {
+	return func(a *AuthService) {
+		a.permissionCombining = c
+	}
+}
+
+// WithStrictRoles makes New reject users with a role which isn't in the RolePermissions, instead of ignoring it.
+func WithStrictRoles() Option {
+	return func(a *AuthService) {
+		a.strictRoles = true
+	}
+}
+
+// WithRoleSources adds sources of roles for the user, after the roles attribute, in order of precedence.
+func WithRoleSources(sources ...RoleSource) Option {
+	return func(a *AuthService) {
+		a.roleSources = append(a.roleSources, sources...)
+	}
+}
+
+// WithRoleMerge sets how the roles from the role sources are merged. Defaults to RoleMergeUnion.
+func WithRoleMerge(m RoleMerge) Option {
+	return func(a *AuthService) {
+		a.roleMerge = m
+	}
+}
+
+// WithMSPRoles maps MSP IDs to roles which every user of the MSP has, in addition to the roles in their roles
+// attribute. Users of a mapped MSP don't need a roles attribute. It is the same as WithRoleSources(MSPRoles(mspRoles)).
+func WithMSPRoles(mspRoles map[string][]string) Option {
+	return WithRoleSources(MSPRoles(mspRoles))
+}
+
+// WithCertRoles derives roles from the user's X.509 certificate with the rules, in addition to the roles in their
+// roles attribute. Users with roles from their certificate don't need a roles attribute.
+// It is the same as WithRoleSources(CertRoles(rules...)).
+func WithCertRoles(rules ...CertRoleRule) Option {
+	return WithRoleSources(CertRoles(rules...))
+}
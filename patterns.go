@@ -0,0 +1,87 @@
+package rbac
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// patternChars are the characters which make a ContractPermissions key a pattern rather than a contract name.
+const patternChars = `*?[\`
+
+// lookup returns the permission for a contract and whether it is set, either by the exact contract name or by a
+// pattern matched with path.Match. An exact name beats any pattern, a more specific pattern beats a less specific
+// one, and deny beats allow between patterns of equal specificity.
+func (cp ContractPermissions) lookup(contractName string) (bool, bool) {
+	if allow, ok := cp[contractName]; ok {
+		return allow, true
+	}
+
+	best := -1
+	allow := false
+
+	for pattern, a := range cp {
+		if !isPattern(pattern) {
+			continue
+		}
+
+		if ok, _ := path.Match(pattern, contractName); !ok {
+			continue
+		}
+
+		switch spec := specificity(pattern); {
+		case spec > best:
+			best, allow = spec, a
+		case spec == best:
+			allow = allow && a
+		}
+	}
+
+	return allow, best >= 0
+}
+
+// validatePatterns checks that every contract pattern of every role is well formed.
+func (rp RolePermissions) validatePatterns() error {
+	for role, perms := range rp {
+		for pattern := range perms.ContractPermissions {
+			if !isPattern(pattern) {
+				continue
+			}
+
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.Wrapf(err, "role `%v` has an invalid contract pattern `%v`", role, pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isPattern returns whether a ContractPermissions key is a pattern.
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, patternChars)
+}
+
+// specificity returns the number of literal characters in a pattern. Wildcards and character classes don't count,
+// so `admin.*` is more specific than `admin*`, which is more specific than `*`.
+func specificity(pattern string) int {
+	n := 0
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+		case '[':
+			for i < len(pattern) && pattern[i] != ']' {
+				i++
+			}
+		case '\\':
+			i++
+			n++
+		default:
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,121 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+func getPatternRolePerms() rbac.RolePermissions {
+	return rbac.RolePermissions{
+		"super": {
+			ContractPermissions: rbac.ContractPermissions{"*": true},
+		},
+		"reader": {
+			ContractPermissions: rbac.ContractPermissions{"query*": true, "queryPrivate": false},
+		},
+		"admin": {
+			ContractPermissions: rbac.ContractPermissions{"admin*": false, "admin.*": true, "admin.delete*": false},
+		},
+		"getter": {
+			ContractPermissions: rbac.ContractPermissions{"get?": true, "get[xy]": false},
+		},
+	}
+}
+
+func TestContractPatterns(t *testing.T) {
+	tests := []struct {
+		cidRoles string
+		contract string
+		expAllow bool
+		msg      string
+	}{
+		{
+			cidRoles: "super",
+			contract: contractCreateTransfer,
+			expAllow: true,
+			msg:      "match any contract with *",
+		},
+		{
+			cidRoles: "reader",
+			contract: "queryWallets",
+			expAllow: true,
+			msg:      "match a prefix pattern",
+		},
+		{
+			cidRoles: "reader",
+			contract: "queryPrivate",
+			expAllow: false,
+			msg:      "prefer an exact match over a pattern",
+		},
+		{
+			cidRoles: "reader",
+			contract: contractCreateWallet,
+			expAllow: false,
+			msg:      "not match a contract outside the pattern",
+		},
+		{
+			cidRoles: "admin",
+			contract: "admin.setPolicy",
+			expAllow: true,
+			msg:      "prefer a more specific pattern",
+		},
+		{
+			cidRoles: "admin",
+			contract: "admin.deleteUser",
+			expAllow: false,
+			msg:      "prefer the most specific pattern",
+		},
+		{
+			cidRoles: "admin",
+			contract: "adminSetPolicy",
+			expAllow: false,
+			msg:      "use the only matching pattern",
+		},
+		{
+			cidRoles: "getter",
+			contract: "getz",
+			expAllow: true,
+			msg:      "use the only matching pattern",
+		},
+		{
+			cidRoles: "getter",
+			contract: "getx",
+			expAllow: false,
+			msg:      "prefer deny between patterns of equal specificity",
+		},
+		{
+			cidRoles: "reader,super",
+			contract: "queryPrivate",
+			expAllow: true,
+			msg:      "combine the roles' permissions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should return allowed %v for %v invoking %v, and %v", tt.expAllow, tt.cidRoles, tt.contract, tt.msg)
+
+		appAuth := simpleSetup(t, nil, getPatternRolePerms(), tt.cidRoles)
+		assert.Equal(t, tt.expAllow, appAuth.ValidateContractPerms(tt.contract) == nil)
+	}
+}
+
+func TestContractPatternErrors(t *testing.T) {
+	t.Log("Should return an error when a contract pattern is malformed")
+
+	rp := rbac.RolePermissions{
+		"user": {ContractPermissions: rbac.ContractPermissions{"query[": true}},
+	}
+
+	_, err := rbac.New(initEmptyStub(), newMockCID(identity{roles: "user"}), rp, "roles")
+
+	if assert.Error(t, err) {
+		if e, ok := err.(rbac.AuthErrorInterface); ok {
+			assert.Equal(t, int32(rbac.CodeErrConfig), e.Code())
+			assert.Equal(t, int32(http.StatusInternalServerError), e.StatusCode())
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Placeholders which can be used in a ResourcePolicy selector and are substituted when the rule is evaluated.
+const (
+	PlaceholderUserID = "${userID}"
+	PlaceholderRoles  = "${roles}"
+)
+
+var placeholderRegexp = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// Policy describes a serialisable set of role permissions which can be compiled in to RolePermissions.
+type Policy struct {
+	Roles map[string]RolePolicy `json:"roles"`
+}
+
+// RolePolicy describes the permissions of a single role within a Policy.
+type RolePolicy struct {
+	Contracts   map[string]bool               `json:"contracts,omitempty"`
+	Operations  map[string]map[Operation]bool `json:"operations,omitempty"`
+	Resources   map[string]ResourcePolicy     `json:"resources,omitempty"`
+	History     map[string]HistoryRule        `json:"history,omitempty"`
+	Collections map[string]CollectionRule     `json:"collections,omitempty"`
+	Inherits    []string                      `json:"inherits,omitempty"`
+}
+
+// ResourcePolicy describes a serialisable QueryRule. The selector can contain placeholders.
+type ResourcePolicy struct {
+	Allow    bool        `json:"allow"`
+	Fields   []string    `json:"fields,omitempty"`
+	Selector CDBSelector `json:"selector,omitempty"`
+}
+
+// ParsePolicyJSON parses a JSON policy document. Unknown fields are rejected so that typos are not silently ignored.
+func ParsePolicyJSON(data []byte) (Policy, error) {
+	var p Policy
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	if err := d.Decode(&p); err != nil {
+		return Policy{}, errPolicy(err)
+	}
+
+	return p, nil
+}
+
+// ParsePolicyYAML parses a YAML policy document.
+func ParsePolicyYAML(data []byte) (Policy, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Policy{}, errPolicy(err)
+	}
+
+	// Round trip through JSON so that YAML and JSON documents decode to exactly the same types
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return Policy{}, errPolicy(err)
+	}
+
+	return ParsePolicyJSON(b)
+}
+
+// RolePermissions validates the policy and compiles it in to RolePermissions which can be passed to New.
+func (p Policy) RolePermissions() (RolePermissions, error) {
+	rp := make(RolePermissions, len(p.Roles))
+
+	for role, r := range p.Roles {
+		perms := Permissions{
+			ContractPermissions:   ContractPermissions{},
+			QueryPermissions:      QueryPermissions{},
+			OperationPermissions:  OperationPermissions{},
+			HistoryPermissions:    HistoryPermissions{},
+			CollectionPermissions: CollectionPermissions{},
+			Inherits:              r.Inherits,
+		}
+
+		for contractName, allow := range r.Contracts {
+			perms.ContractPermissions[contractName] = allow
+		}
+
+		for resource, ops := range r.Operations {
+			perms.OperationPermissions[resource] = make(map[Operation]bool, len(ops))
+
+			for op, allow := range ops {
+				if !op.valid() {
+					return nil, errPolicy(errors.Errorf("role `%v` has invalid operation `%v` on %v", role, op, resource))
+				}
+
+				perms.OperationPermissions[resource][op] = allow
+			}
+		}
+
+		for resource, res := range r.Resources {
+			if err := validatePlaceholders(res.Selector); err != nil {
+				return nil, errPolicy(errors.Wrapf(err, "role `%v` has an invalid %v selector", role, resource))
+			}
+
+			perms.QueryPermissions[resource] = res.ruleFunc()
+		}
+
+		for resource, rule := range r.History {
+			perms.HistoryPermissions[resource] = rule
+		}
+
+		for collection, rule := range r.Collections {
+			perms.CollectionPermissions[collection] = rule
+		}
+
+		rp[role] = perms
+	}
+
+	if _, err := rp.flatten(); err != nil {
+		return nil, errPolicy(err)
+	}
+
+	if err := rp.validatePatterns(); err != nil {
+		return nil, errPolicy(err)
+	}
+
+	return rp, nil
+}
+
+// ruleFunc returns a QueryRuleFunc which substitutes the selector placeholders for the current user.
+func (r ResourcePolicy) ruleFunc() QueryRuleFunc {
+	return func(userID string, userRoles []string) QueryRule {
+		rule := QueryRule{
+			Allow:       r.Allow,
+			FieldFilter: r.Fields,
+		}
+
+		if r.Selector != nil {
+			rule.SelectorAppend = expandPlaceholders(r.Selector, userID, userRoles).(CDBSelector)
+		}
+
+		return rule
+	}
+}
+
+// validatePlaceholders checks that all placeholders in a selector are known.
+func validatePlaceholders(v interface{}) error {
+	switch val := v.(type) {
+	case CDBSelector:
+		return validatePlaceholders(map[string]interface{}(val))
+	case map[string]interface{}:
+		for _, child := range val {
+			if err := validatePlaceholders(child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			if err := validatePlaceholders(child); err != nil {
+				return err
+			}
+		}
+	case string:
+		for _, ph := range placeholderRegexp.FindAllString(val, -1) {
+			if ph != PlaceholderUserID && ph != PlaceholderRoles {
+				return errors.Errorf("unknown placeholder `%v`", ph)
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandPlaceholders returns a deep copy of v with all placeholders substituted.
+// A string which is exactly the roles placeholder is replaced with an array of roles,
+// otherwise roles are substituted as a comma separated list.
+func expandPlaceholders(v interface{}, userID string, userRoles []string) interface{} {
+	switch val := v.(type) {
+	case CDBSelector:
+		return CDBSelector(expandPlaceholders(map[string]interface{}(val), userID, userRoles).(map[string]interface{}))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			m[k] = expandPlaceholders(child, userID, userRoles)
+		}
+
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, child := range val {
+			s[i] = expandPlaceholders(child, userID, userRoles)
+		}
+
+		return s
+	case string:
+		if val == PlaceholderRoles {
+			roles := make([]interface{}, len(userRoles))
+			for i, role := range userRoles {
+				roles[i] = role
+			}
+
+			return roles
+		}
+
+		return strings.NewReplacer(
+			PlaceholderUserID, userID,
+			PlaceholderRoles, strings.Join(userRoles, ","),
+		).Replace(val)
+	default:
+		return val
+	}
+}
@@ -0,0 +1,251 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+const policyJSON = `
+{
+  "roles": {
+    "admin": {
+      "contracts": { "createTransfer": true, "createWallet": false, "queryLedger": true },
+      "operations": { "transfer": { "create": true, "delete": true } },
+      "resources": {
+        "asset": { "allow": true, "fields": ["createdBy", "created"] },
+        "transfer": { "allow": true },
+        "wallet": { "allow": false }
+      },
+      "history": { "transfer": { "allow": true, "includeDeletes": true } },
+      "collections": { "pvt": { "read": true } }
+    },
+    "user": {
+      "contracts": { "createWallet": true, "queryLedger": true },
+      "resources": {
+        "transfer": {
+          "allow": true,
+          "selector": {
+            "$or": [
+              { "createdBy": "${userID}" },
+              { "asset.from": "${userID}" },
+              { "asset.to": "${userID}" },
+              { "payment.from": "${userID}" },
+              { "payment.to": "${userID}" }
+            ]
+          }
+        },
+        "wallet": { "allow": true, "selector": { "createdBy": "${userID}" } },
+        "asset": { "allow": true, "selector": { "visibleTo": { "$in": "${roles}" } } }
+      }
+    },
+    "manager": {
+      "inherits": ["user"],
+      "contracts": { "createTransfer": true }
+    }
+  }
+}`
+
+const policyYAML = `
+roles:
+  admin:
+    contracts:
+      createTransfer: true
+      createWallet: false
+      queryLedger: true
+    operations:
+      transfer:
+        create: true
+        delete: true
+    resources:
+      asset:
+        allow: true
+        fields: [createdBy, created]
+      transfer:
+        allow: true
+      wallet:
+        allow: false
+    history:
+      transfer:
+        allow: true
+        includeDeletes: true
+    collections:
+      pvt:
+        read: true
+  user:
+    contracts:
+      createWallet: true
+      queryLedger: true
+    resources:
+      transfer:
+        allow: true
+        selector:
+          $or:
+            - createdBy: ${userID}
+            - asset.from: ${userID}
+            - asset.to: ${userID}
+            - payment.from: ${userID}
+            - payment.to: ${userID}
+      wallet:
+        allow: true
+        selector:
+          createdBy: ${userID}
+      asset:
+        allow: true
+        selector:
+          visibleTo:
+            $in: ${roles}
+  manager:
+    inherits: [user]
+    contracts:
+      createTransfer: true
+`
+
+const expQueryVisibleToRoles = `
+{
+  "selector": {
+    "docType": "asset",
+    "visibleTo": { "$in": ["user"] }
+  },
+  "limit": 10
+}`
+
+func TestPolicy(t *testing.T) {
+	parsers := map[string]func() (rbac.Policy, error){
+		"JSON": func() (rbac.Policy, error) { return rbac.ParsePolicyJSON([]byte(policyJSON)) },
+		"YAML": func() (rbac.Policy, error) { return rbac.ParsePolicyYAML([]byte(policyYAML)) },
+	}
+
+	tests := []struct {
+		res      string
+		cidRoles string
+		expQ     string
+	}{
+		{
+			res:      resourceTransfer,
+			cidRoles: "user",
+			expQ:     expQueryInTransfer,
+		},
+		{
+			res:      resourceWallet,
+			cidRoles: "user",
+			expQ:     expQueryOnlyCreatedBy(resourceWallet),
+		},
+		{
+			res:      resourceAsset,
+			cidRoles: "user",
+			expQ:     expQueryVisibleToRoles,
+		},
+		{
+			res:      resourceTransfer,
+			cidRoles: "admin",
+			expQ:     doctypeQuery(resourceTransfer),
+		},
+		{
+			res:      resourceAsset,
+			cidRoles: "admin",
+			expQ:     expQueryLimitFields(resourceAsset),
+		},
+	}
+
+	for format, parse := range parsers {
+		p, err := parse()
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		rp, err := p.RolePermissions()
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		for _, tt := range tests {
+			t.Logf("Should compile a %v policy which allows %v to query %vs", format, tt.cidRoles, tt.res)
+
+			appAuth := simpleSetup(t, nil, rp, tt.cidRoles)
+			payload, err := appAuth.ValidateQueryPerms(doctypeQuery(tt.res))
+
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expQ, payload)
+		}
+
+		t.Logf("Should compile %v policy contract and operation permissions", format)
+
+		appAuth := simpleSetup(t, nil, rp, "admin")
+		assert.NoError(t, appAuth.ValidateContractPerms(contractCreateTransfer))
+		assert.Error(t, appAuth.ValidateContractPerms(contractCreateWallet))
+		assert.NoError(t, appAuth.ValidateOperationPerms(resourceTransfer, rbac.OperationDelete))
+		assert.Error(t, appAuth.ValidateOperationPerms(resourceTransfer, rbac.OperationUpdate))
+
+		_, err = appAuth.ValidateQueryPerms(doctypeQuery(resourceWallet))
+		assert.Error(t, err)
+
+		t.Logf("Should compile %v policy role inheritance", format)
+
+		appAuth = simpleSetup(t, nil, rp, "manager")
+		assert.NoError(t, appAuth.ValidateContractPerms(contractCreateTransfer))
+		assert.NoError(t, appAuth.ValidateContractPerms(contractCreateWallet))
+
+		t.Logf("Should compile %v policy history and collection permissions", format)
+
+		expRule := rbac.HistoryRule{Allow: true, IncludeDeletes: true}
+		assert.Equal(t, expRule, rp["admin"].HistoryPermissions[resourceTransfer])
+		assert.False(t, rp["user"].HistoryPermissions[resourceWallet].Allow)
+		assert.Equal(t, rbac.CollectionRule{Read: true}, rp["admin"].CollectionPermissions[collectionPvt])
+	}
+}
+
+func TestPolicyErrors(t *testing.T) {
+	tests := []struct {
+		doc string
+		msg string
+	}{
+		{
+			doc: `{"roles": {"user": {"contracts": {"createWallet": "yes"}}}}`,
+			msg: "a contract permission is not a bool",
+		},
+		{
+			doc: `{"roles": {"user": {"contract": {"createWallet": true}}}}`,
+			msg: "the document has an unknown field",
+		},
+		{
+			doc: `{"roles": {"user": {"operations": {"wallet": {"archive": true}}}}}`,
+			msg: "an operation is unknown",
+		},
+		{
+			doc: `{"roles": {"user": {"resources": {"wallet": {"allow": true, "selector": {"owner": "${mspID}"}}}}}}`,
+			msg: "a selector placeholder is unknown",
+		},
+		{
+			doc: `{"roles": {"user": {"inherits": ["manager"]}, "manager": {"inherits": ["user"]}}}`,
+			msg: "roles inherit from each other",
+		},
+		{
+			doc: `{"roles": {"user": {"contracts": {"query[": true}}}}`,
+			msg: "a contract pattern is malformed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should return an error with code %v when %v", rbac.CodeErrPolicy, tt.msg)
+
+		p, err := rbac.ParsePolicyJSON([]byte(tt.doc))
+		if err == nil {
+			_, err = p.RolePermissions()
+		}
+
+		if assert.Error(t, err) {
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrPolicy), e.Code())
+				assert.Equal(t, int32(http.StatusBadRequest), e.StatusCode())
+			}
+		}
+	}
+
+	_, err := rbac.ParsePolicyYAML([]byte(mock.Anything + ": [unclosed"))
+	assert.Error(t, err)
+}
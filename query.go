@@ -0,0 +1,242 @@
+package rbac
+
+import "strings"
+
+// queryDocTypes returns every docType referenced by the docType condition at the root of the selector.
+// The condition can be a string, or an object using the `$eq` and `$in` operators.
+func queryDocTypes(selector CDBSelector) ([]string, error) {
+	switch cond := selector["docType"].(type) {
+	case string:
+		if cond == "" {
+			return nil, errQueryDocType()
+		}
+
+		return []string{cond}, nil
+	case map[string]interface{}:
+		var docTypes []string
+
+		for op, v := range cond {
+			switch op {
+			case "$eq":
+				docType, ok := v.(string)
+				if !ok || docType == "" {
+					return nil, errQueryDocType()
+				}
+
+				docTypes = appendUnique(docTypes, docType)
+			case "$in":
+				values, ok := v.([]interface{})
+				if !ok || len(values) == 0 {
+					return nil, errQueryDocType()
+				}
+
+				for _, value := range values {
+					docType, ok := value.(string)
+					if !ok || docType == "" {
+						return nil, errQueryDocType()
+					}
+
+					docTypes = appendUnique(docTypes, docType)
+				}
+			default:
+				return nil, errQueryDocTypeOperator(op)
+			}
+		}
+
+		if len(docTypes) == 0 {
+			return nil, errQueryDocType()
+		}
+
+		return docTypes, nil
+	default:
+		return nil, errQueryDocType()
+	}
+}
+
+// docTypesRule returns the user's QueryRule for a query across all the given docTypes.
+// When there is more than one docType, each docType's selector only applies to documents of that docType
+// and only fields allowed for all of the docTypes are returned, as CouchDB can't filter fields per docType.
+func (a AuthService) docTypesRule(docTypes []string) (QueryRule, error) {
+	rules := make([]QueryRule, len(docTypes))
+
+	for i, docType := range docTypes {
+		rule, ok, err := a.queryRule(docType)
+		if err != nil {
+			return QueryRule{}, err
+		}
+
+		if !ok {
+			return QueryRule{}, errQuery(docType)
+		}
+
+		rules[i] = rule
+	}
+
+	if len(rules) == 1 {
+		return rules[0], nil
+	}
+
+	combined := QueryRule{Allow: true}
+	branches := make([]interface{}, len(rules))
+	restricted := false
+
+	for i, rule := range rules {
+		if len(rule.SelectorAppend) > 0 {
+			restricted = true
+		}
+
+		branches[i] = mergeSelectors(CDBSelector{"docType": docTypes[i]}, rule.SelectorAppend)
+
+		switch {
+		case rule.FieldFilter == nil:
+		case combined.FieldFilter == nil:
+			combined.FieldFilter = rule.FieldFilter
+		default:
+			combined.FieldFilter = intersectFields(combined.FieldFilter, rule.FieldFilter)
+		}
+	}
+
+	if combined.FieldFilter != nil && len(combined.FieldFilter) == 0 {
+		return QueryRule{}, errQueryFields()
+	}
+
+	if restricted {
+		combined.SelectorAppend = CDBSelector{"$or": branches}
+	}
+
+	return combined, nil
+}
+
+// checkFieldRefs returns an error if the query's selector or sort references a field which is not visible, as
+// the hidden value could otherwise be inferred from which documents are returned, or the order they are returned in.
+// A nil visible list means all fields are visible.
+func checkFieldRefs(q CDBQuery, visible []string) error {
+	if visible == nil {
+		return nil
+	}
+
+	refs := selectorFieldRefs(map[string]interface{}(q.Selector), "", nil)
+	for _, s := range q.Sort {
+		refs = append(refs, s.Field)
+	}
+
+	for _, ref := range refs {
+		if !fieldVisible(ref, visible) {
+			return errQueryField(ref)
+		}
+	}
+
+	return nil
+}
+
+// selectorFieldRefs appends the path of every field referenced in the selector to refs.
+func selectorFieldRefs(v interface{}, path string, refs []string) []string {
+	var m map[string]interface{}
+
+	switch val := v.(type) {
+	case CDBSelector:
+		m = val
+	case map[string]interface{}:
+		m = val
+	default:
+		// A literal value is an implicit `$eq` on the field
+		if path != "" {
+			refs = append(refs, path)
+		}
+
+		return refs
+	}
+
+	if len(m) == 0 && path != "" {
+		refs = append(refs, path)
+	}
+
+	for k, child := range m {
+		if !strings.HasPrefix(k, "$") {
+			refs = selectorFieldRefs(child, joinPath(path, k), refs)
+			continue
+		}
+
+		switch k {
+		case "$and", "$or", "$nor":
+			if children, ok := child.([]interface{}); ok {
+				for _, c := range children {
+					refs = selectorFieldRefs(c, path, refs)
+				}
+
+				continue
+			}
+
+			refs = selectorFieldRefs(child, path, refs)
+		case "$not", "$elemMatch", "$allMatch":
+			// Sub-selectors apply to the current field, or to fields of its array elements
+			refs = selectorFieldRefs(child, path, refs)
+		default:
+			// Any other operator is a condition on the current field
+			if path != "" {
+				refs = append(refs, path)
+			}
+		}
+	}
+
+	return refs
+}
+
+// fieldVisible returns whether the field path is, or is nested within, one of the visible fields.
+// The docType is always visible as every query must reference it.
+func fieldVisible(path string, visible []string) bool {
+	if path == "docType" {
+		return true
+	}
+
+	for _, f := range visible {
+		if path == f || strings.HasPrefix(path, f+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinPath joins a field path and a key with a dot.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// projectFields returns the fields which should be returned for a query, by intersecting the fields requested
+// with the visible fields. A requested field which contains visible fields is narrowed to those fields.
+// A nil visible list means all fields are visible, and no requested fields means all visible fields.
+func projectFields(requested, visible []string) ([]string, error) {
+	if visible == nil {
+		return requested, nil
+	}
+
+	if len(requested) == 0 {
+		return visible, nil
+	}
+
+	var fields []string
+
+	for _, r := range requested {
+		if fieldVisible(r, visible) {
+			fields = appendUnique(fields, r)
+			continue
+		}
+
+		for _, f := range visible {
+			if strings.HasPrefix(f, r+".") {
+				fields = appendUnique(fields, f)
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, errQueryFields()
+	}
+
+	return fields, nil
+}
@@ -3,34 +3,47 @@ package rbac
 
 import (
 	"encoding/json"
+	"io"
+	"strings"
 
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
 )
 
 // AuthServiceInterface is exported so that it can be used by consuming applications as a helper.
 type AuthServiceInterface interface {
 	GetUserID() string
 	GetUserRoles() []string
+	GetState(key string) ([]byte, error)
 	ValidateContractPerms(contractName string) error
+	ValidateOperationPerms(resource string, op Operation) error
 	ValidateQueryPerms(query string) (string, error)
 	WithContractAuth(contractName string, args []string, contract ContractFunc) ([]byte, error)
 }
 
 // AuthService describes the auth service.
 type AuthService struct {
-	rolePermissions RolePermissions
-	stub            shim.ChaincodeStubInterface
-	userID          string
-	userRoles       []string
+	rolePermissions     RolePermissions
+	stub                shim.ChaincodeStubInterface
+	userID              string
+	userRoles           []string
+	queryCombining      QueryCombining
+	permissionCombining PermissionCombining
+	failClosed          bool
+	ruleContext         *RuleContext
+	roleSources         []RoleSource
+	roleMerge           RoleMerge
+	strictRoles         bool
 }
 
-// New returns a concrete AuthService type.
+// New returns a concrete AuthService type, configured by any given Options.
 func New(
 	stub shim.ChaincodeStubInterface,
 	clientIdentity cid.ClientIdentity,
 	rolePermissions RolePermissions,
 	rolesAttr string,
+	opts ...Option,
 ) (AuthService, error) {
 	var a AuthService
 
@@ -39,32 +52,76 @@ func New(
 		return a, errAuthentication(err)
 	}
 
-	userRoles, err := getRoles(clientIdentity, rolesAttr)
+	rolePermissions, err = rolePermissions.flatten()
 	if err != nil {
-		return a, err
+		return a, errConfig(err)
+	}
+
+	if err := rolePermissions.validatePatterns(); err != nil {
+		return a, errConfig(err)
 	}
 
 	a = AuthService{
 		rolePermissions: rolePermissions,
 		stub:            stub,
 		userID:          userID,
-		userRoles:       userRoles,
+	}
+
+	for _, opt := range opts {
+		opt(&a)
+	}
+
+	if err := validateRoleSources(a.roleSources); err != nil {
+		return AuthService{}, errConfig(err)
+	}
+
+	a.userRoles, err = a.getUserRoles(clientIdentity, rolesAttr)
+	if err != nil {
+		return AuthService{}, err
+	}
+
+	a.ruleContext = &RuleContext{
+		Stub:           stub,
+		ClientIdentity: clientIdentity,
+		UserID:         a.userID,
+		UserRoles:      a.userRoles,
 	}
 
 	return a, nil
 }
 
 // ValidateContractPerms validates whether the given roles have permission to invoke a contract.
+// ContractPermissions keys can be patterns, such as `query*` or `*`, which are matched with path.Match.
+// The permissions of the roles are combined with the AuthService's PermissionCombining.
 func (a AuthService) ValidateContractPerms(contractName string) error {
-	for _, role := range a.userRoles {
-		// Lookup permissions
-		perm := a.rolePermissions[role].ContractPermissions[contractName]
-		if perm {
-			return nil
-		}
+	permitted := a.permitted(func(perms Permissions) (bool, bool) {
+		return perms.ContractPermissions.lookup(contractName)
+	})
+
+	if !permitted {
+		return errContract()
+	}
+
+	return nil
+}
+
+// ValidateOperationPerms validates whether the given roles have permission to perform an operation on a resource.
+// The permissions of the roles are combined with the AuthService's PermissionCombining.
+func (a AuthService) ValidateOperationPerms(resource string, op Operation) error {
+	if !op.valid() {
+		return errOperationType(op)
 	}
 
-	return errContract()
+	permitted := a.permitted(func(perms Permissions) (bool, bool) {
+		allow, ok := perms.OperationPermissions[resource][op]
+		return allow, ok
+	})
+
+	if !permitted {
+		return errOperation(resource, op)
+	}
+
+	return nil
 }
 
 // GetUserID returns the current user's ID.
@@ -80,56 +137,90 @@ func (a AuthService) GetUserRoles() []string {
 // ValidateQueryPerms validates if user can perform query and enforces CouchDB query filters where required.
 func (a AuthService) ValidateQueryPerms(q string) (string, error) {
 	var newQ CDBQuery
-	// Unmarshal in to a CDBQuery
-	if err := json.Unmarshal([]byte(q), &newQ); err != nil {
+	// Unmarshal in to a CDBQuery, keeping numbers as json.Numbers so they are marshalled back without losing precision
+	dec := json.NewDecoder(strings.NewReader(q))
+	dec.UseNumber()
+
+	if err := dec.Decode(&newQ); err != nil {
 		return "", errQueryMarshal(err)
 	}
 
-	// Pick out the doctype from the query
-	resource := newQ.Selector["docType"]
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return "", errQueryMarshal(errors.New("unexpected data after the query"))
+	}
 
-	if resource == nil || resource == "" {
-		return "", errQueryDocType()
+	// Pick out the doctypes from the query
+	docTypes, err := queryDocTypes(newQ.Selector)
+	if err != nil {
+		return "", err
 	}
 
-	for _, role := range a.userRoles {
-		// Lookup permissions
-		ruleFunc, ok := a.rolePermissions[role].QueryPermissions[resource.(string)]
-		if !ok {
-			continue
-		}
+	rules, err := a.docTypesRule(docTypes)
+	if err != nil {
+		return "", err
+	}
 
-		// Construct rules from the ruleFunc callback
-		rules := ruleFunc(a.userID, a.userRoles)
-		if !rules.Allow {
-			continue
-		}
+	// Prevent hidden fields from being inferred through the caller's selector or sort.
+	// Conditions added by a previous rewrite are not the caller's, so rewriting a rewritten query is a no-op.
+	ownQ := newQ
+	ownQ.Selector = ownConditions(newQ.Selector, rules.SelectorAppend)
 
-		// Enforce any selector appends
-		for k, v := range rules.SelectorAppend {
-			newQ.Selector[k] = v
-		}
+	if err := checkFieldRefs(ownQ, rules.FieldFilter); err != nil {
+		return "", err
+	}
+
+	// Enforce any selector appends, without replacing any of the caller's conditions
+	newQ.Selector = mergeSelectors(newQ.Selector, rules.SelectorAppend)
 
-		// Enforce any filter queries (no need to check for nil first)
-		newQ.Fields = rules.FieldFilter
+	// Enforce any filter queries, within the fields the caller requested
+	newQ.Fields, err = projectFields(newQ.Fields, rules.FieldFilter)
+	if err != nil {
+		return "", err
+	}
 
-		// Marshal back to json bytes so it can be sent back as a string
-		newQBytes, err := json.Marshal(newQ)
-		if err != nil {
-			return "", errMarshal(err)
+	// Marshal back to json bytes so it can be sent back as a string
+	newQBytes, err := json.Marshal(newQ)
+	if err != nil {
+		return "", errMarshal(err)
+	}
+
+	return string(newQBytes), nil
+}
+
+// ValidateContractArgs validates whether the given roles have permission to invoke a contract with the args.
+// A role which allows the contract allows the args if it has no ContractCondition for the contract, or the
+// condition is true, otherwise it denies them. The roles are combined with the AuthService's PermissionCombining,
+// in the same way as for ValidateContractPerms.
+func (a AuthService) ValidateContractArgs(contractName string, args []string) error {
+	permitted := a.permitted(func(perms Permissions) (bool, bool) {
+		allow, ok := perms.ContractPermissions.lookup(contractName)
+		if !allow || !ok {
+			return allow, ok
 		}
 
-		return string(newQBytes), nil
+		cond, ok := perms.ContractConditions[contractName]
+
+		return !ok || cond(args, a.userID, a.userRoles), true
+	})
+
+	if !permitted {
+		return errContractArgs(contractName)
 	}
 
-	return "", errQuery(resource.(string))
+	return nil
 }
 
-// WithContractAuth wraps a chaincode contract and only invokes it if contract RBAC passes.
+// WithContractAuth wraps a chaincode contract and only invokes it if contract RBAC passes,
+// including any ContractConditions on the args.
+// The contract is passed an AuthorizedStub, which enforces the user's permissions on state access.
 func (a AuthService) WithContractAuth(contractName string, args []string, contract ContractFunc) ([]byte, error) {
 	if err := a.ValidateContractPerms(contractName); err != nil {
 		return nil, err
 	}
 
-	return contract(a.stub, args, a)
+	if err := a.ValidateContractArgs(contractName, args); err != nil {
+		return nil, err
+	}
+
+	return contract(a.AuthorizedStub(), args, a)
 }
@@ -53,7 +53,7 @@ func TestValidateContractPerms(t *testing.T) {
 	for _, tt := range tests {
 		t.Logf("%v %v to invoke %v contract", tt.msg, tt.cidRoles, tt.cRef)
 
-		appAuth := simpleSetup(t, tt.cidRoles)
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
 		err := appAuth.ValidateContractPerms(tt.cRef)
 
 		if !tt.allow {
@@ -64,6 +64,72 @@ func TestValidateContractPerms(t *testing.T) {
 	}
 }
 
+func TestValidateOperationPerms(t *testing.T) {
+	tests := []struct {
+		res      string
+		op       rbac.Operation
+		cidRoles string
+		allow    bool
+		msg      string
+	}{
+		{
+			res:      resourceWallet,
+			op:       rbac.OperationCreate,
+			cidRoles: "user",
+			allow:    true,
+			msg:      "Should allow",
+		},
+		{
+			res:      resourceWallet,
+			op:       rbac.OperationDelete,
+			cidRoles: "user",
+			allow:    false,
+			msg:      "Should not allow",
+		},
+		{
+			res:      resourceTransfer,
+			op:       rbac.OperationCreate,
+			cidRoles: "user",
+			allow:    false,
+			msg:      "Should not allow",
+		},
+		{
+			res:      resourceTransfer,
+			op:       rbac.OperationUpdate,
+			cidRoles: "admin",
+			allow:    true,
+			msg:      "Should allow",
+		},
+		{
+			res:      resourceWallet,
+			op:       rbac.OperationRead,
+			cidRoles: "admin",
+			allow:    false,
+			msg:      "Should not allow",
+		},
+		{
+			res:      resourceWallet,
+			op:       rbac.OperationDelete,
+			cidRoles: "user,admin",
+			allow:    true,
+			msg:      "Should allow",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("%v %v to %v %v records", tt.msg, tt.cidRoles, tt.op, tt.res)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
+		err := appAuth.ValidateOperationPerms(tt.res, tt.op)
+
+		if !tt.allow {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
 func TestWithContractAuth(t *testing.T) {
 	args := []string{mock.Anything}
 
@@ -89,7 +155,7 @@ func TestWithContractAuth(t *testing.T) {
 			"Should successfully return payload to a user with role %v from contract with ref %v", tt.cidRoles, tt.cRef,
 		)
 
-		appAuth := simpleSetup(t, tt.cidRoles)
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
 		payload, err := appAuth.WithContractAuth(tt.cRef, args, tt.c)
 		assert.NoError(t, err)
 		assert.Equal(t, mockPayload, payload)
@@ -125,7 +191,7 @@ func TestValidateQueryPerms(t *testing.T) {
 	for _, tt := range tests {
 		t.Logf("Should allow %v to query %vs, and %v", tt.cidRoles, tt.res, tt.msg)
 
-		appAuth := simpleSetup(t, tt.cidRoles)
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
 		q := `{"selector": {"docType": "` + tt.res + `"}, "limit": 10}`
 		payload, err := appAuth.ValidateQueryPerms(q)
 
@@ -134,6 +200,570 @@ func TestValidateQueryPerms(t *testing.T) {
 	}
 }
 
+func TestValidateQueryPermsSelectorMerge(t *testing.T) {
+	tests := []struct {
+		q        string
+		cidRoles string
+		expQ     string
+		msg      string
+	}{
+		{
+			q:        `{"selector": {"docType": "wallet", "createdBy": "anotherUserID"}}`,
+			cidRoles: "user",
+			expQ: `{"selector": {
+				"docType": "wallet",
+				"createdBy": "anotherUserID",
+				"$and": [{"createdBy": "testuserID"}]
+			}}`,
+			msg: "constrain the caller's createdBy rather than replacing it",
+		},
+		{
+			q:        `{"selector": {"docType": "transfer", "$or": [{"status": "pending"}, {"status": "failed"}]}}`,
+			cidRoles: "user",
+			expQ: `{"selector": {
+				"docType": "transfer",
+				"$or": [{"status": "pending"}, {"status": "failed"}],
+				"$and": [{"$or": [
+					{"createdBy": "testuserID"},
+					{"asset.from": "testuserID"},
+					{"asset.to": "testuserID"},
+					{"payment.from": "testuserID"},
+					{"payment.to": "testuserID"}
+				]}]
+			}}`,
+			msg: "combine the caller's $or with the rule's $or",
+		},
+		{
+			q:        `{"selector": {"docType": "wallet", "createdBy": "anotherUserID", "$and": [{"balance": {"$gt": 0}}]}}`,
+			cidRoles: "user",
+			expQ: `{"selector": {
+				"docType": "wallet",
+				"createdBy": "anotherUserID",
+				"$and": [{"balance": {"$gt": 0}}, {"createdBy": "testuserID"}]
+			}}`,
+			msg: "append to the caller's $and",
+		},
+		{
+			q:        `{"selector": {"docType": "wallet", "balance": {"$gt": 0}}}`,
+			cidRoles: "user",
+			expQ:     `{"selector": {"docType": "wallet", "balance": {"$gt": 0}, "createdBy": "testuserID"}}`,
+			msg:      "add non-conflicting conditions at the root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should %v", tt.msg)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
+		payload, err := appAuth.ValidateQueryPerms(tt.q)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, tt.expQ, payload)
+	}
+}
+
+func TestValidateQueryPermsDocTypeOperators(t *testing.T) {
+	tests := []struct {
+		q        string
+		cidRoles string
+		expQ     string
+		msg      string
+	}{
+		{
+			q:        `{"selector": {"docType": {"$eq": "wallet"}}}`,
+			cidRoles: "user",
+			expQ:     `{"selector": {"docType": {"$eq": "wallet"}, "createdBy": "testuserID"}}`,
+			msg:      "enforce the wallet rule for an $eq docType",
+		},
+		{
+			q:        `{"selector": {"docType": {"$in": ["transfer", "wallet"]}}}`,
+			cidRoles: "user",
+			expQ: `{"selector": {
+				"docType": {"$in": ["transfer", "wallet"]},
+				"$or": [
+					{
+						"docType": "transfer",
+						"$or": [
+							{"createdBy": "testuserID"},
+							{"asset.from": "testuserID"},
+							{"asset.to": "testuserID"},
+							{"payment.from": "testuserID"},
+							{"payment.to": "testuserID"}
+						]
+					},
+					{"docType": "wallet", "createdBy": "testuserID"}
+				]
+			}}`,
+			msg: "enforce each docType's rule only on documents of that docType",
+		},
+		{
+			q:        `{"selector": {"docType": {"$in": ["asset", "transfer"]}}}`,
+			cidRoles: "admin",
+			expQ:     `{"selector": {"docType": {"$in": ["asset", "transfer"]}}, "fields": ["createdBy", "created"]}`,
+			msg:      "only return fields allowed for all docTypes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should %v", tt.msg)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
+		payload, err := appAuth.ValidateQueryPerms(tt.q)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, tt.expQ, payload)
+	}
+}
+
+func TestValidateQueryPermsMangoFields(t *testing.T) {
+	tests := []struct {
+		q        string
+		cidRoles string
+		expQ     string
+		msg      string
+	}{
+		{
+			q:        mangoQuery,
+			cidRoles: "admin",
+			expQ:     mangoQuery,
+			msg:      "preserve every Mango query field when the query is not altered",
+		},
+		{
+			q: `{
+				"selector": {"docType": "wallet"},
+				"sort": [{"created": "asc"}, "balance"],
+				"use_index": "_design/wallets",
+				"bookmark": "g1AAAABweJzLYWBgYMpgSmHgKy5JLCrJTq2MT8lPzkzJBYqzGBkZGBgaGRqYmpqaWViCAA",
+				"update": true
+			}`,
+			cidRoles: "user",
+			expQ:     expMangoQueryOnlyCreatedBy,
+			msg:      "preserve the ordered sort, index and bookmark when the selector is altered",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should %v", tt.msg)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
+		payload, err := appAuth.ValidateQueryPerms(tt.q)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, tt.expQ, payload)
+	}
+}
+
+func TestValidateQueryPermsNumbers(t *testing.T) {
+	tests := []struct {
+		cidRoles string
+		msg      string
+	}{
+		{
+			cidRoles: "admin",
+			msg:      "when the query is not altered",
+		},
+		{
+			cidRoles: "user",
+			msg:      "when the selector is altered",
+		},
+	}
+
+	q := `{"selector": {"docType": "transfer", "amount": {"$gt": 12345678901234567891, "$lt": 1.10}}}`
+
+	for _, tt := range tests {
+		t.Logf("Should preserve integers above 2^53 and decimals exactly %v", tt.msg)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
+		payload, err := appAuth.ValidateQueryPerms(q)
+
+		if assert.NoError(t, err) {
+			assert.Contains(t, payload, `"$gt":12345678901234567891`)
+			assert.Contains(t, payload, `"$lt":1.10`)
+		}
+	}
+}
+
+func TestValidateQueryPermsIdempotent(t *testing.T) {
+	rp := getRolePerms()
+	rp["balances"] = rbac.Permissions{
+		QueryPermissions: rbac.QueryPermissions{resourceWallet: ownBalance},
+	}
+
+	tests := []struct {
+		q        string
+		cidRoles string
+		msg      string
+	}{
+		{
+			q:        `{"selector": {"docType": "wallet", "balance": {"$gt": 0}}}`,
+			cidRoles: "balances",
+			msg:      "when the rule appends a field outside its field filter",
+		},
+		{
+			q:        `{"selector": {"docType": "wallet", "createdBy": "anotherUserID"}}`,
+			cidRoles: "user",
+			msg:      "when the rule's conditions conflict with the caller's",
+		},
+		{
+			q:        `{"selector": {"docType": "transfer", "$or": [{"status": "pending"}, {"status": "failed"}]}}`,
+			cidRoles: "user",
+			msg:      "when the rule's $or is added to the caller's $and",
+		},
+		{
+			q:        `{"selector": {"docType": "transfer", "created": {"$gt": 12345678901234567891}}}`,
+			cidRoles: "auditor",
+			msg:      "when the rule filters fields and appends a condition",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should return the same query when a rewritten query is validated again %v", tt.msg)
+
+		appAuth := simpleSetup(t, nil, rp, tt.cidRoles)
+
+		q, err := appAuth.ValidateQueryPerms(tt.q)
+		if assert.NoError(t, err) {
+			again, err := appAuth.ValidateQueryPerms(q)
+			assert.NoError(t, err)
+			assert.Equal(t, q, again)
+		}
+	}
+}
+
+func TestValidateQueryPermsVisibleFieldRefs(t *testing.T) {
+	appAuth := simpleSetup(t, nil, nil, "admin")
+	q := `{
+		"selector": {"docType": "asset", "createdBy": "testuserID", "$or": [{"created": {"$gt": 1}}]},
+		"sort": [{"created": "desc"}]
+	}`
+
+	t.Log("Should allow admin to query assets by visible fields")
+
+	payload, err := appAuth.ValidateQueryPerms(q)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"selector": {"docType": "asset", "createdBy": "testuserID", "$or": [{"created": {"$gt": 1}}]},
+		"sort": [{"created": "desc"}],
+		"fields": ["createdBy", "created"]
+	}`, payload)
+}
+
+func TestValidateQueryPermsFieldProjection(t *testing.T) {
+	p, err := rbac.ParsePolicyJSON([]byte(`{"roles": {"user": {"resources": {
+		"transfer": {"allow": true, "fields": ["id", "asset.from", "asset.to"]}
+	}}}}`))
+	if err != nil {
+		t.Fatalf("Parsing policy failed unexpectedly: %v", err)
+	}
+
+	rp, err := p.RolePermissions()
+	if err != nil {
+		t.Fatalf("Compiling policy failed unexpectedly: %v", err)
+	}
+
+	tests := []struct {
+		appAuth rbac.AuthServiceInterface
+		q       string
+		expQ    string
+		msg     string
+	}{
+		{
+			appAuth: simpleSetup(t, nil, nil, "admin"),
+			q:       `{"selector": {"docType": "asset"}, "fields": ["createdBy", "salary"]}`,
+			expQ:    `{"selector": {"docType": "asset"}, "fields": ["createdBy"]}`,
+			msg:     "only return the requested fields which are allowed",
+		},
+		{
+			appAuth: simpleSetup(t, nil, nil, "admin"),
+			q:       `{"selector": {"docType": "asset"}}`,
+			expQ:    `{"selector": {"docType": "asset"}, "fields": ["createdBy", "created"]}`,
+			msg:     "return all allowed fields when no fields are requested",
+		},
+		{
+			appAuth: simpleSetup(t, nil, nil, "admin"),
+			q:       `{"selector": {"docType": "transfer"}, "fields": ["amount", "status"]}`,
+			expQ:    `{"selector": {"docType": "transfer"}, "fields": ["amount", "status"]}`,
+			msg:     "keep the requested fields when the rule has no field filter",
+		},
+		{
+			appAuth: simpleSetup(t, nil, rp, "user"),
+			q:       `{"selector": {"docType": "transfer"}, "fields": ["asset", "asset.from", "id.hash"]}`,
+			expQ:    `{"selector": {"docType": "transfer"}, "fields": ["asset.from", "asset.to", "id.hash"]}`,
+			msg:     "narrow a requested field to the allowed fields nested within it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should %v", tt.msg)
+
+		payload, err := tt.appAuth.ValidateQueryPerms(tt.q)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, tt.expQ, payload)
+	}
+}
+
+func TestQueryCombining(t *testing.T) {
+	tests := []struct {
+		res       string
+		cidRoles  string
+		combining rbac.QueryCombining
+		expQ      string
+		msg       string
+	}{
+		{
+			res:       resourceTransfer,
+			cidRoles:  "user,auditor",
+			combining: rbac.QueryCombineUnion,
+			expQ:      expQueryInTransferOrCompleted,
+			msg:       "combine selectors with $or and keep only the fields allowed by every role",
+		},
+		{
+			res:       resourceTransfer,
+			cidRoles:  "auditor,admin",
+			combining: rbac.QueryCombineUnion,
+			expQ:      doctypeQuery(resourceTransfer),
+			msg:       "not alter the query when one role is unrestricted",
+		},
+		{
+			res:       resourceWallet,
+			cidRoles:  "auditor,user",
+			combining: rbac.QueryCombineUnion,
+			expQ:      expQueryOnlyCreatedBy(resourceWallet),
+			msg:       "ignore a role which disallows",
+		},
+		{
+			res:       resourceTransfer,
+			cidRoles:  "user,auditor",
+			combining: rbac.QueryCombineFirstMatch,
+			expQ:      expQueryInTransfer,
+			msg:       "use the first role's rule",
+		},
+		{
+			res:       resourceTransfer,
+			cidRoles:  "auditor,user",
+			combining: rbac.QueryCombineFirstMatch,
+			expQ:      expQueryCompleted,
+			msg:       "use the first role's rule",
+		},
+		{
+			res:       resourceTransfer,
+			cidRoles:  "auditor,user",
+			combining: rbac.QueryCombineMostPermissive,
+			expQ:      expQueryInTransfer,
+			msg:       "use the rule without a field filter",
+		},
+		{
+			res:       resourceTransfer,
+			cidRoles:  "auditor,user,admin",
+			combining: rbac.QueryCombineMostPermissive,
+			expQ:      doctypeQuery(resourceTransfer),
+			msg:       "use the rule without a selector",
+		},
+		{
+			res:       resourceTransfer,
+			cidRoles:  "user,auditor",
+			combining: rbac.QueryCombineMostRestrictive,
+			expQ:      expQueryInTransferAndCompleted,
+			msg:       "combine selectors with $and and keep the field filter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should allow %v to query %vs with combining %v, and %v", tt.cidRoles, tt.res, tt.combining, tt.msg)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles, rbac.WithQueryCombining(tt.combining))
+		payload, err := appAuth.ValidateQueryPerms(doctypeQuery(tt.res))
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, tt.expQ, payload)
+	}
+}
+
+func TestQueryCombiningFieldFilters(t *testing.T) {
+	names := func(userID string, userRoles []string) rbac.QueryRule {
+		return rbac.QueryRule{Allow: true, FieldFilter: []string{"name"}}
+	}
+	nothing := func(userID string, userRoles []string) rbac.QueryRule {
+		return rbac.QueryRule{Allow: true, FieldFilter: []string{}}
+	}
+	rp := rbac.RolePermissions{
+		"owner":   {QueryPermissions: rbac.QueryPermissions{resourceAsset: owner}},
+		"names":   {QueryPermissions: rbac.QueryPermissions{resourceAsset: names}},
+		"nothing": {QueryPermissions: rbac.QueryPermissions{resourceAsset: nothing}},
+	}
+
+	tests := []struct {
+		cidRoles  string
+		combining rbac.QueryCombining
+		expQ      string
+		msg       string
+	}{
+		{
+			cidRoles:  "owner,names",
+			combining: rbac.QueryCombineUnion,
+			expQ:      `{"selector": {"docType": "asset"}, "fields": ["name"], "limit": 10}`,
+			msg:       "only return the fields allowed by every role when the roles differ in selector and field filter",
+		},
+		{
+			cidRoles:  "names,nothing",
+			combining: rbac.QueryCombineUnion,
+			expQ:      `{"selector": {"docType": "asset"}, "fields": ["name"], "limit": 10}`,
+			msg:       "ignore a role with an empty field filter",
+		},
+		{
+			cidRoles:  "nothing,owner",
+			combining: rbac.QueryCombineFirstMatch,
+			expQ:      `{"selector": {"docType": "asset", "createdBy": "testuserID"}, "limit": 10}`,
+			msg:       "skip a role with an empty field filter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should allow %v to query assets with combining %v, and %v", tt.cidRoles, tt.combining, tt.msg)
+
+		appAuth := simpleSetup(t, nil, rp, tt.cidRoles, rbac.WithQueryCombining(tt.combining))
+		payload, err := appAuth.ValidateQueryPerms(doctypeQuery(resourceAsset))
+
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, tt.expQ, payload)
+		}
+	}
+
+	t.Log("Should return only the fields allowed by every role from a document read by key")
+
+	stub := initEmptyStub()
+	stub.MockTransactionStart("tx")
+	assert.NoError(t, stub.PutState("a1", []byte(`{"docType": "asset", "createdBy": "testuserID", "name": "a"}`)))
+
+	payload, err := simpleSetup(t, stub, rp, "owner,names").GetState("a1")
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"name": "a"}`, string(payload))
+	}
+
+	for _, combining := range []rbac.QueryCombining{
+		rbac.QueryCombineUnion,
+		rbac.QueryCombineFirstMatch,
+		rbac.QueryCombineMostPermissive,
+		rbac.QueryCombineMostRestrictive,
+	} {
+		t.Logf("Should return an error with code %v and HTTP status code %v when the only field filter is empty "+
+			"with combining %v", rbac.CodeErrQuery, http.StatusForbidden, combining)
+
+		appAuth := simpleSetup(t, nil, rp, "nothing", rbac.WithQueryCombining(combining))
+
+		_, err := appAuth.ValidateQueryPerms(doctypeQuery(resourceAsset))
+		if assert.Error(t, err) {
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrQuery), e.Code())
+				assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+			}
+		}
+	}
+
+	t.Log("Should deny a query when one role's field filter is empty with combining most restrictive")
+
+	appAuth := simpleSetup(t, nil, rp, "nothing,owner", rbac.WithQueryCombining(rbac.QueryCombineMostRestrictive))
+
+	_, err = appAuth.ValidateQueryPerms(doctypeQuery(resourceAsset))
+	assert.Error(t, err)
+}
+
+func TestPermissionCombining(t *testing.T) {
+	tests := []struct {
+		cidRoles    string
+		combining   rbac.PermissionCombining
+		contract    string
+		res         string
+		op          rbac.Operation
+		expContract bool
+		expOp       bool
+	}{
+		{
+			cidRoles:    "user,suspended",
+			combining:   rbac.PermitOverrides,
+			contract:    contractQueryLedger,
+			res:         resourceWallet,
+			op:          rbac.OperationCreate,
+			expContract: true,
+			expOp:       true,
+		},
+		{
+			cidRoles:    "user,suspended",
+			combining:   rbac.DenyOverrides,
+			contract:    contractQueryLedger,
+			res:         resourceWallet,
+			op:          rbac.OperationCreate,
+			expContract: false,
+			expOp:       false,
+		},
+		{
+			cidRoles:    "suspended,user",
+			combining:   rbac.FirstApplicable,
+			contract:    contractQueryLedger,
+			res:         resourceWallet,
+			op:          rbac.OperationCreate,
+			expContract: false,
+			expOp:       false,
+		},
+		{
+			cidRoles:    "user,suspended",
+			combining:   rbac.FirstApplicable,
+			contract:    contractQueryLedger,
+			res:         resourceWallet,
+			op:          rbac.OperationCreate,
+			expContract: true,
+			expOp:       true,
+		},
+		{
+			cidRoles:    "admin,user",
+			combining:   rbac.FirstApplicable,
+			contract:    contractCreateWallet,
+			res:         resourceWallet,
+			op:          rbac.OperationDelete,
+			expContract: false,
+			expOp:       true,
+		},
+		{
+			cidRoles:    "user,admin",
+			combining:   rbac.FirstApplicable,
+			contract:    contractCreateWallet,
+			res:         resourceWallet,
+			op:          rbac.OperationDelete,
+			expContract: true,
+			expOp:       false,
+		},
+		{
+			cidRoles:    "user,suspended",
+			combining:   rbac.DenyOverrides,
+			contract:    contractCreateWallet,
+			res:         resourceWallet,
+			op:          rbac.OperationRead,
+			expContract: true,
+			expOp:       true,
+		},
+		{
+			cidRoles:    "suspended",
+			combining:   rbac.DenyOverrides,
+			contract:    contractCreateWallet,
+			res:         resourceTransfer,
+			op:          rbac.OperationRead,
+			expContract: false,
+			expOp:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should allow %v to invoke %v (%v) and %v %vs (%v) with combining %v",
+			tt.cidRoles, tt.contract, tt.expContract, tt.op, tt.res, tt.expOp, tt.combining)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles, rbac.WithPermissionCombining(tt.combining))
+
+		assert.Equal(t, tt.expContract, appAuth.ValidateContractPerms(tt.contract) == nil)
+		assert.Equal(t, tt.expOp, appAuth.ValidateOperationPerms(tt.res, tt.op) == nil)
+	}
+}
+
 func TestContractQuery(t *testing.T) {
 	tests := []struct {
 		args     []string
@@ -182,7 +812,7 @@ func TestContractQuery(t *testing.T) {
 			"%v as payload to user with role %v from contract with ref %v", tt.msg, tt.cidRoles, tt.cRef,
 		)
 
-		appAuth := simpleSetup(t, tt.cidRoles)
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
 		payload, err := appAuth.WithContractAuth(tt.cRef, tt.args, tt.c)
 		assert.NoError(t, err)
 		assert.JSONEq(t, tt.expPL, string(payload))
@@ -299,6 +929,24 @@ func TestContractQueryErrors(t *testing.T) {
 			expC:     rbac.CodeErrQueryMarshal,
 			msg:      "malformed json",
 		},
+		{
+			args:     []string{`{"selector": {"docType": "transfer"}, "sort": [{"created": "asc", "status": "desc"}]}`},
+			cRef:     contractQueryLedger,
+			c:        mockQueryContract,
+			cidRoles: "admin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrQueryMarshal,
+			msg:      "sort field with more than one field",
+		},
+		{
+			args:     []string{`{"selector": {"docType": "transfer"}} {"selector": {"docType": "wallet"}}`},
+			cRef:     contractQueryLedger,
+			c:        mockQueryContract,
+			cidRoles: "admin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrQueryMarshal,
+			msg:      "data after the query",
+		},
 		{
 			args:     []string{`{"selector": {"notDocType": "anything"}, "limit": 10}`},
 			cRef:     contractQueryLedger,
@@ -320,7 +968,7 @@ func TestContractQueryErrors(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		appAuth := simpleSetup(t, tt.cidRoles)
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
 		_, err := appAuth.WithContractAuth(tt.cRef, tt.args, tt.c)
 
 		if assert.Error(t, err) {
@@ -335,24 +983,77 @@ func TestContractQueryErrors(t *testing.T) {
 }
 
 func TestValidateQueryPermsErrors(t *testing.T) {
+	tests := []struct {
+		res       string
+		cidRoles  string
+		combining rbac.QueryCombining
+		expSC     int32
+		expC      int32
+	}{
+		{
+			res:      resourceTransfer,
+			cidRoles: "unknownRole",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrQuery,
+		},
+		{
+			res:       resourceWallet,
+			cidRoles:  "user,auditor",
+			combining: rbac.QueryCombineMostRestrictive,
+			expSC:     http.StatusForbidden,
+			expC:      rbac.CodeErrQuery,
+		},
+	}
+	for _, tt := range tests {
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles, rbac.WithQueryCombining(tt.combining))
+		q := `{"selector": {"docType": "` + tt.res + `"}, "limit": 10}`
+
+		_, err := appAuth.ValidateQueryPerms(q)
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v\nerr: %v", tt.expC, tt.expSC, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
+
+func TestValidateOperationPermsErrors(t *testing.T) {
 	tests := []struct {
 		res      string
+		op       rbac.Operation
 		cidRoles string
 		expSC    int32
 		expC     int32
 	}{
 		{
 			res:      resourceTransfer,
+			op:       rbac.OperationCreate,
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrOperation,
+		},
+		{
+			res:      resourceTransfer,
+			op:       rbac.OperationRead,
 			cidRoles: "unknownRole",
 			expSC:    http.StatusForbidden,
-			expC:     rbac.CodeErrQuery,
+			expC:     rbac.CodeErrOperation,
+		},
+		{
+			res:      resourceTransfer,
+			op:       "archive",
+			cidRoles: "admin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrOperationType,
 		},
 	}
 	for _, tt := range tests {
-		appAuth := simpleSetup(t, tt.cidRoles)
-		q := `{"selector": {"docType": "` + tt.res + `"}, "limit": 10}`
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
 
-		_, err := appAuth.ValidateQueryPerms(q)
+		err := appAuth.ValidateOperationPerms(tt.res, tt.op)
 		if assert.Error(t, err) {
 			t.Logf("Should return an error with code %v and HTTP status code %v\nerr: %v", tt.expC, tt.expSC, err)
 
@@ -363,3 +1064,139 @@ func TestValidateQueryPermsErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateQueryPermsDocTypeErrors(t *testing.T) {
+	tests := []struct {
+		q     string
+		expSC int32
+		expC  int32
+		msg   string
+	}{
+		{
+			q:     `{"selector": {"docType": {"$regex": "^wal"}}}`,
+			expSC: http.StatusBadRequest,
+			expC:  rbac.CodeErrQueryDocTypeOperator,
+			msg:   "the docType operator is unsupported",
+		},
+		{
+			q:     `{"selector": {"docType": {"$eq": "wallet", "$ne": "transfer"}}}`,
+			expSC: http.StatusBadRequest,
+			expC:  rbac.CodeErrQueryDocTypeOperator,
+			msg:   "one of the docType operators is unsupported",
+		},
+		{
+			q:     `{"selector": {"docType": {"$in": "wallet"}}}`,
+			expSC: http.StatusBadRequest,
+			expC:  rbac.CodeErrQueryDocType,
+			msg:   "$in is not an array",
+		},
+		{
+			q:     `{"selector": {"docType": {"$in": []}}}`,
+			expSC: http.StatusBadRequest,
+			expC:  rbac.CodeErrQueryDocType,
+			msg:   "$in is empty",
+		},
+		{
+			q:     `{"selector": {"docType": {"$eq": 1}}}`,
+			expSC: http.StatusBadRequest,
+			expC:  rbac.CodeErrQueryDocType,
+			msg:   "$eq is not a string",
+		},
+		{
+			q:     `{"selector": {"docType": ["wallet"]}}`,
+			expSC: http.StatusBadRequest,
+			expC:  rbac.CodeErrQueryDocType,
+			msg:   "docType is an array",
+		},
+		{
+			q:     `{"selector": {"docType": {}}}`,
+			expSC: http.StatusBadRequest,
+			expC:  rbac.CodeErrQueryDocType,
+			msg:   "docType is an empty object",
+		},
+		{
+			q:     `{"selector": {"docType": {"$in": ["transfer", "wallet"]}}}`,
+			expSC: http.StatusForbidden,
+			expC:  rbac.CodeErrQuery,
+			msg:   "one of the docTypes is forbidden",
+		},
+	}
+	for _, tt := range tests {
+		appAuth := simpleSetup(t, nil, nil, "admin")
+
+		_, err := appAuth.ValidateQueryPerms(tt.q)
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v when %v\nerr: %v",
+				tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
+
+func TestValidateQueryPermsFieldProjectionErrors(t *testing.T) {
+	appAuth := simpleSetup(t, nil, nil, "admin")
+
+	_, err := appAuth.ValidateQueryPerms(`{"selector": {"docType": "asset"}, "fields": ["salary", "owner"]}`)
+	if assert.Error(t, err) {
+		t.Logf("Should return an error with code %v when none of the requested fields are allowed\nerr: %v",
+			rbac.CodeErrQueryFields, err)
+
+		if e, ok := err.(rbac.AuthErrorInterface); ok {
+			assert.Equal(t, int32(rbac.CodeErrQueryFields), e.Code())
+			assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+		}
+	}
+}
+
+func TestValidateQueryPermsHiddenFieldErrors(t *testing.T) {
+	tests := []struct {
+		q   string
+		msg string
+	}{
+		{
+			q:   `{"selector": {"docType": "asset", "salary": {"$gt": 100000}}}`,
+			msg: "the selector has an operator on a hidden field",
+		},
+		{
+			q:   `{"selector": {"docType": "asset", "salary": 100000}}`,
+			msg: "the selector has an implicit $eq on a hidden field",
+		},
+		{
+			q:   `{"selector": {"docType": "asset", "$or": [{"createdBy": "testuserID"}, {"salary": 100000}]}}`,
+			msg: "the selector has a hidden field in $or",
+		},
+		{
+			q:   `{"selector": {"docType": "asset", "$not": {"owner.salary": {"$lt": 100000}}}}`,
+			msg: "the selector has a hidden field in $not",
+		},
+		{
+			q:   `{"selector": {"docType": "asset", "owner": {"salary": {"$gt": 100000}}}}`,
+			msg: "the selector has a nested hidden field",
+		},
+		{
+			q:   `{"selector": {"docType": "asset", "payments": {"$elemMatch": {"amount": {"$gt": 100}}}}}`,
+			msg: "the selector has a hidden field in $elemMatch",
+		},
+		{
+			q:   `{"selector": {"docType": "asset"}, "sort": ["createdBy", {"salary": "desc"}]}`,
+			msg: "the sort has a hidden field",
+		},
+	}
+	for _, tt := range tests {
+		appAuth := simpleSetup(t, nil, nil, "admin")
+
+		_, err := appAuth.ValidateQueryPerms(tt.q)
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v when %v\nerr: %v", rbac.CodeErrQueryField, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrQueryField), e.Code())
+				assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+			}
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package rbac
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
+)
+
+// Contract names of the role administration ContractFuncs, granted to the RoleRegistry's admin role.
+const (
+	ContractGetRoles    = "getRoles"
+	ContractAssignRoles = "assignRoles"
+	ContractRevokeRoles = "revokeRoles"
+)
+
+// RoleAction describes the change recorded in a RoleAssignment.
+type RoleAction string
+
+// Role assignment changes.
+const (
+	RoleActionAssign RoleAction = "assign"
+	RoleActionRevoke RoleAction = "revoke"
+)
+
+// roleAssignmentObjectType is the composite key object type role assignments are stored under, keyed by user ID.
+// Composite keys live in their own namespace so assignments can not collide with application keys, and the
+// AuthorizedStub doesn't allow keys in the rbac~ namespace to be written.
+const roleAssignmentObjectType = "rbac~roles"
+
+// RoleAssignment describes the roles assigned to a user in the world state.
+// Every change increments the Version and records the change, so the ledger history of the record is an audit
+// trail of who assigned or revoked which roles and when.
+type RoleAssignment struct {
+	Roles     []string   `json:"roles"`
+	Version   uint64     `json:"version,omitempty"`
+	Action    RoleAction `json:"action,omitempty"`
+	Changed   []string   `json:"changed,omitempty"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+	TxID      string     `json:"txId,omitempty"`
+	Timestamp string     `json:"timestamp,omitempty"`
+}
+
+// RoleRegistry stores the roles assigned to users in the world state and provides ContractFuncs to administer them,
+// so a user's roles can be changed without re-enrolling them.
+type RoleRegistry struct {
+	// AdminRole is always allowed to get, assign and revoke roles.
+	AdminRole string
+}
+
+// NewRoleRegistry returns a RoleRegistry which is administered by the admin role.
+func NewRoleRegistry(adminRole string) RoleRegistry {
+	return RoleRegistry{AdminRole: adminRole}
+}
+
+// New returns a concrete AuthService type, with the roles assigned to the user in the registry merged with the
+// roles from their certificate. The admin role is granted the role administration contracts.
+func (r RoleRegistry) New(
+	stub shim.ChaincodeStubInterface,
+	clientIdentity cid.ClientIdentity,
+	rolePermissions RolePermissions,
+	rolesAttr string,
+	opts ...Option,
+) (AuthService, error) {
+	rp := make(RolePermissions, len(rolePermissions)+1)
+	for role, perms := range rolePermissions {
+		rp[role] = perms
+	}
+
+	perms := rp[r.AdminRole]
+	contractPerms := make(ContractPermissions, len(perms.ContractPermissions)+3)
+
+	for name, allow := range perms.ContractPermissions {
+		contractPerms[name] = allow
+	}
+
+	contractPerms[ContractGetRoles] = true
+	contractPerms[ContractAssignRoles] = true
+	contractPerms[ContractRevokeRoles] = true
+	perms.ContractPermissions = contractPerms
+	rp[r.AdminRole] = perms
+
+	opts = append([]Option{WithRoleSources(LedgerRoles())}, opts...)
+
+	return New(stub, clientIdentity, rp, rolesAttr, opts...)
+}
+
+// Load returns the RoleAssignment stored for the user, or an empty assignment with version 0 if there is none.
+func (r RoleRegistry) Load(stub shim.ChaincodeStubInterface, userID string) (RoleAssignment, error) {
+	return loadRoleAssignment(unwrapStub(stub), userID)
+}
+
+// Assign adds the roles to the user's assignment and stores it as a new version, recorded as changed by the auth
+// service's user. Roles which aren't defined in the auth service's RolePermissions are rejected, so a mistyped role
+// can't be assigned.
+func (r RoleRegistry) Assign(
+	stub shim.ChaincodeStubInterface,
+	auth AuthServiceInterface,
+	userID string,
+	roles []string,
+) (RoleAssignment, error) {
+	definer, ok := auth.(roleDefiner)
+	if !ok {
+		return RoleAssignment{}, errRoleAssignment(errors.New("roles can't be checked against the role permissions"))
+	}
+
+	for _, role := range normaliseRoles(roles) {
+		if !definer.definesRole(role) {
+			return RoleAssignment{}, errRoleAssignment(errors.Errorf("role `%v` is not defined", role))
+		}
+	}
+
+	return r.save(stub, userID, RoleActionAssign, roles, auth.GetUserID())
+}
+
+// Revoke removes the roles from the user's assignment and stores it as a new version, recorded as changed by the
+// auth service's user. Revoking a role the user hasn't been assigned, or which isn't defined, is not an error.
+func (r RoleRegistry) Revoke(
+	stub shim.ChaincodeStubInterface,
+	auth AuthServiceInterface,
+	userID string,
+	roles []string,
+) (RoleAssignment, error) {
+	return r.save(stub, userID, RoleActionRevoke, roles, auth.GetUserID())
+}
+
+// GetRoles is a ContractFunc which returns the RoleAssignment of the user ID in args[0].
+// Only the admin role may invoke it.
+func (r RoleRegistry) GetRoles(
+	stub shim.ChaincodeStubInterface,
+	args []string,
+	auth AuthServiceInterface,
+) ([]byte, error) {
+	if !contains(auth.GetUserRoles(), r.AdminRole) {
+		return nil, errContract()
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		return nil, errRoleAssignment(errors.New("user ID must be provided as the first argument"))
+	}
+
+	rec, err := r.Load(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalRoleAssignment(rec)
+}
+
+// AssignRoles is a ContractFunc which assigns the roles in args[1:] to the user ID in args[0].
+// Only the admin role may invoke it.
+func (r RoleRegistry) AssignRoles(
+	stub shim.ChaincodeStubInterface,
+	args []string,
+	auth AuthServiceInterface,
+) ([]byte, error) {
+	return r.change(stub, args, auth, RoleActionAssign)
+}
+
+// RevokeRoles is a ContractFunc which revokes the roles in args[1:] from the user ID in args[0].
+// Only the admin role may invoke it.
+func (r RoleRegistry) RevokeRoles(
+	stub shim.ChaincodeStubInterface,
+	args []string,
+	auth AuthServiceInterface,
+) ([]byte, error) {
+	return r.change(stub, args, auth, RoleActionRevoke)
+}
+
+// change checks the invoking user is an admin and applies the change in the args.
+func (r RoleRegistry) change(
+	stub shim.ChaincodeStubInterface,
+	args []string,
+	auth AuthServiceInterface,
+	action RoleAction,
+) ([]byte, error) {
+	if !contains(auth.GetUserRoles(), r.AdminRole) {
+		return nil, errContract()
+	}
+
+	if len(args) < 2 || args[0] == "" {
+		return nil, errRoleAssignment(errors.New("user ID and at least one role must be provided as arguments"))
+	}
+
+	var (
+		rec RoleAssignment
+		err error
+	)
+
+	switch action {
+	case RoleActionAssign:
+		rec, err = r.Assign(stub, auth, args[0], args[1:])
+	default:
+		rec, err = r.Revoke(stub, auth, args[0], args[1:])
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalRoleAssignment(rec)
+}
+
+// save applies the change to the user's assignment and stores it as a new version, recording who made the change
+// and in which transaction.
+func (r RoleRegistry) save(
+	stub shim.ChaincodeStubInterface,
+	userID string,
+	action RoleAction,
+	roles []string,
+	updatedBy string,
+) (RoleAssignment, error) {
+	stub = unwrapStub(stub)
+
+	roles = normaliseRoles(roles)
+	if len(roles) == 0 {
+		return RoleAssignment{}, errRoleAssignment(errors.New("no roles given"))
+	}
+
+	rec, err := loadRoleAssignment(stub, userID)
+	if err != nil {
+		return rec, err
+	}
+
+	assigned := rec.Roles
+
+	switch action {
+	case RoleActionAssign:
+		assigned = appendUnique(assigned, roles...)
+	case RoleActionRevoke:
+		assigned = nil
+
+		for _, role := range rec.Roles {
+			if !contains(roles, role) {
+				assigned = append(assigned, role)
+			}
+		}
+	default:
+		return rec, errRoleAssignment(errors.Errorf("unknown action `%v`", action))
+	}
+
+	rec = RoleAssignment{
+		Roles:     assigned,
+		Version:   rec.Version + 1,
+		Action:    action,
+		Changed:   roles,
+		UpdatedBy: updatedBy,
+		TxID:      stub.GetTxID(),
+		Timestamp: txTimestamp(stub),
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return rec, errMarshal(err)
+	}
+
+	key, err := stub.CreateCompositeKey(roleAssignmentObjectType, []string{userID})
+	if err != nil {
+		return rec, errLedger(err)
+	}
+
+	if err := stub.PutState(key, b); err != nil {
+		return rec, errLedger(err)
+	}
+
+	return rec, nil
+}
+
+// roleDefiner is implemented by AuthService, so assigned roles can be checked against its RolePermissions.
+type roleDefiner interface {
+	definesRole(role string) bool
+}
+
+// definesRole returns whether the role is defined in the RolePermissions.
+func (a AuthService) definesRole(role string) bool {
+	_, ok := a.rolePermissions[role]
+	return ok
+}
+
+// loadRoleAssignment returns the RoleAssignment stored for the user, or an empty one if there is none.
+func loadRoleAssignment(stub shim.ChaincodeStubInterface, userID string) (RoleAssignment, error) {
+	var rec RoleAssignment
+
+	key, err := stub.CreateCompositeKey(roleAssignmentObjectType, []string{userID})
+	if err != nil {
+		return rec, errLedger(err)
+	}
+
+	b, err := unwrapStub(stub).GetState(key)
+	if err != nil {
+		return rec, errLedger(err)
+	}
+
+	if b == nil {
+		return rec, nil
+	}
+
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return rec, errLedger(err)
+	}
+
+	return rec, nil
+}
+
+// marshalRoleAssignment returns the RoleAssignment as the payload of a ContractFunc.
+func marshalRoleAssignment(rec RoleAssignment) ([]byte, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, errMarshal(err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,230 @@
+package rbac_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+func TestRoleRegistry(t *testing.T) {
+	stub := initEmptyStub()
+	registry := rbac.NewRoleRegistry("admin")
+
+	t.Log("Should return a roles error before any roles are assigned to a user without a roles attribute")
+
+	_, err := registry.New(stub, newMockCID(identity{userID: "bob"}), getRolePerms(), "roles")
+	assert.Error(t, err)
+
+	t.Log("Should allow the admin role to assign roles and record who assigned them in which transaction")
+
+	stub.MockTransactionStart("tx1")
+	appAuth, err := registry.New(stub, newMockCID(identity{userID: "alice", roles: "admin"}), getRolePerms(), "roles")
+	if assert.NoError(t, err) {
+		payload, err := appAuth.WithContractAuth(
+			rbac.ContractAssignRoles,
+			[]string{"bob", "auditor", " user", "auditor"},
+			registry.AssignRoles,
+		)
+
+		if assert.NoError(t, err) {
+			var rec rbac.RoleAssignment
+
+			assert.NoError(t, json.Unmarshal(payload, &rec))
+			assert.Equal(t, []string{"auditor", "user"}, rec.Roles)
+			assert.Equal(t, uint64(1), rec.Version)
+			assert.Equal(t, rbac.RoleActionAssign, rec.Action)
+			assert.Equal(t, []string{"auditor", "user"}, rec.Changed)
+			assert.Equal(t, "alice", rec.UpdatedBy)
+			assert.Equal(t, "tx1", rec.TxID)
+			assert.NotEmpty(t, rec.Timestamp)
+		}
+	}
+	stub.MockTransactionEnd("tx1")
+
+	t.Log("Should give the user their assigned roles in New")
+
+	appAuth, err = registry.New(stub, newMockCID(identity{userID: "bob"}), getRolePerms(), "roles")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"auditor", "user"}, appAuth.GetUserRoles())
+	}
+
+	t.Log("Should merge the assigned roles with the roles attribute")
+
+	appAuth, err = registry.New(stub, newMockCID(identity{userID: "bob", roles: "user,admin"}), getRolePerms(), "roles")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"user", "admin", "auditor"}, appAuth.GetUserRoles())
+	}
+
+	t.Log("Should allow the admin role to revoke roles and increment the version")
+
+	stub.MockTransactionStart("tx2")
+	appAuth, err = registry.New(stub, newMockCID(identity{userID: "alice", roles: "admin"}), getRolePerms(), "roles")
+	if assert.NoError(t, err) {
+		payload, err := appAuth.WithContractAuth(
+			rbac.ContractRevokeRoles,
+			[]string{"bob", "auditor", "superuser"},
+			registry.RevokeRoles,
+		)
+
+		if assert.NoError(t, err) {
+			var rec rbac.RoleAssignment
+
+			assert.NoError(t, json.Unmarshal(payload, &rec))
+			assert.Equal(t, []string{"user"}, rec.Roles)
+			assert.Equal(t, uint64(2), rec.Version)
+			assert.Equal(t, rbac.RoleActionRevoke, rec.Action)
+			assert.Equal(t, []string{"auditor", "superuser"}, rec.Changed)
+			assert.Equal(t, "tx2", rec.TxID)
+		}
+
+		payload, err = appAuth.WithContractAuth(rbac.ContractGetRoles, []string{"bob"}, registry.GetRoles)
+		if assert.NoError(t, err) {
+			var rec rbac.RoleAssignment
+
+			assert.NoError(t, json.Unmarshal(payload, &rec))
+			assert.Equal(t, []string{"user"}, rec.Roles)
+		}
+	}
+	stub.MockTransactionEnd("tx2")
+
+	appAuth, err = registry.New(stub, newMockCID(identity{userID: "bob"}), getRolePerms(), "roles")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"user"}, appAuth.GetUserRoles())
+	}
+}
+
+func TestRoleRegistryErrors(t *testing.T) {
+	stub := initEmptyStub()
+	registry := rbac.NewRoleRegistry("admin")
+
+	tests := []struct {
+		args     []string
+		c        rbac.ContractFunc
+		cRef     string
+		cidRoles string
+		expSC    int32
+		expC     int32
+		msg      string
+	}{
+		{
+			args:     []string{"bob", "admin"},
+			c:        registry.AssignRoles,
+			cRef:     rbac.ContractAssignRoles,
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrContract,
+			msg:      "when a role other than the admin role assigns roles",
+		},
+		{
+			args:     []string{"bob", "admin"},
+			c:        registry.RevokeRoles,
+			cRef:     rbac.ContractRevokeRoles,
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrContract,
+			msg:      "when a role other than the admin role revokes roles",
+		},
+		{
+			args:     []string{"bob"},
+			c:        registry.GetRoles,
+			cRef:     rbac.ContractGetRoles,
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrContract,
+			msg:      "when a role other than the admin role gets a user's roles",
+		},
+		{
+			args:     []string{"bob"},
+			c:        registry.AssignRoles,
+			cRef:     rbac.ContractAssignRoles,
+			cidRoles: "admin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrRoleAssignment,
+			msg:      "when no roles are given",
+		},
+		{
+			args:     []string{"bob", "user", "auditr"},
+			c:        registry.AssignRoles,
+			cRef:     rbac.ContractAssignRoles,
+			cidRoles: "admin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrRoleAssignment,
+			msg:      "when a role isn't defined in the role permissions",
+		},
+		{
+			args:     []string{"bob", " ", ""},
+			c:        registry.AssignRoles,
+			cRef:     rbac.ContractAssignRoles,
+			cidRoles: "admin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrRoleAssignment,
+			msg:      "when only empty roles are given",
+		},
+		{
+			args:     nil,
+			c:        registry.GetRoles,
+			cRef:     rbac.ContractGetRoles,
+			cidRoles: "admin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrRoleAssignment,
+			msg:      "when no user ID is given",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should return an error with code %v and HTTP status code %v %v", tt.expC, tt.expSC, tt.msg)
+
+		stub.MockTransactionStart("tx")
+		cid := newMockCID(identity{userID: "alice", roles: tt.cidRoles})
+
+		appAuth, err := registry.New(stub, cid, getRolePerms(), "roles")
+		if assert.NoError(t, err) {
+			_, err = appAuth.WithContractAuth(tt.cRef, tt.args, tt.c)
+		}
+		stub.MockTransactionEnd("tx")
+
+		if assert.Error(t, err) {
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+
+	rec, err := registry.Load(stub, "bob")
+	if assert.NoError(t, err) {
+		assert.Empty(t, rec.Roles)
+	}
+}
+
+func TestRoleRegistryReservedKey(t *testing.T) {
+	const (
+		victimKey = "\x00rbac~roles\x00bob\x00"
+		escalate  = `{"docType": "wallet", "roles": ["admin"]}`
+	)
+
+	t.Log("Should not allow a user to write a role assignment through the AuthorizedStub")
+
+	stub := initEmptyStub()
+	registry := rbac.NewRoleRegistry("admin")
+
+	stub.MockTransactionStart("tx1")
+	appAuth, err := registry.New(stub, newMockCID(identity{userID: "alice", roles: "user"}), getRolePerms(), "roles")
+	if assert.NoError(t, err) {
+		_, err = appAuth.WithContractAuth(contractCreateWallet, nil, putContract(victimKey, escalate))
+
+		if assert.Error(t, err) {
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, int32(rbac.CodeErrReservedKey), e.Code())
+			}
+		}
+	}
+	stub.MockTransactionEnd("tx1")
+
+	_, err = registry.New(stub, newMockCID(identity{userID: "bob"}), getRolePerms(), "roles")
+	assert.Error(t, err)
+}
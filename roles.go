@@ -0,0 +1,202 @@
+package rbac
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
+)
+
+// RoleSource provides roles for the current user. A source which has no roles for the user returns none,
+// without an error, so that other sources can provide them.
+type RoleSource interface {
+	Roles(stub shim.ChaincodeStubInterface, clientIdentity cid.ClientIdentity) ([]string, error)
+}
+
+// RoleMerge describes how the roles from several RoleSources are merged.
+type RoleMerge int
+
+const (
+	// RoleMergeUnion gives the user the roles from all of the sources, in the order of the sources.
+	RoleMergeUnion RoleMerge = iota
+	// RoleMergeFirst gives the user the roles from the first source, in order, which has any roles for them.
+	RoleMergeFirst
+)
+
+// AttributeRoles returns a RoleSource which reads roles from a certificate attribute, either as a comma separated
+// list or as a JSON array of strings. This is the source New uses for its rolesAttr argument.
+func AttributeRoles(attr string) RoleSource {
+	return attributeRoles(attr)
+}
+
+// MSPRoles returns a RoleSource which gives every user of an MSP the MSP's roles.
+func MSPRoles(mspRoles map[string][]string) RoleSource {
+	return mspRoleSource(mspRoles)
+}
+
+// CertRoles returns a RoleSource which derives roles from the user's X.509 certificate with the rules.
+func CertRoles(rules ...CertRoleRule) RoleSource {
+	return certRoleSource(rules)
+}
+
+// LedgerRoles returns a RoleSource which reads the RoleAssignment stored in the world state for the user's ID.
+func LedgerRoles() RoleSource {
+	return ledgerRoles{}
+}
+
+type attributeRoles string
+
+// Roles returns the roles in the attribute, or none if the user doesn't have the attribute.
+func (s attributeRoles) Roles(stub shim.ChaincodeStubInterface, clientIdentity cid.ClientIdentity) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	val, found, err := clientIdentity.GetAttributeValue(string(s))
+	if err != nil {
+		return nil, errAuthentication(err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	roles, err := parseRoles(val)
+	if err != nil {
+		return nil, errRolesAttr(string(s), err)
+	}
+
+	return roles, nil
+}
+
+// parseRoles parses a roles attribute value. Values starting with `[` are decoded as a JSON array of strings,
+// anything else is split on commas. Roles are trimmed of whitespace, and empty and duplicate roles are removed.
+func parseRoles(val string) ([]string, error) {
+	var roles []string
+
+	val = strings.TrimSpace(val)
+
+	if strings.HasPrefix(val, "[") {
+		if err := json.Unmarshal([]byte(val), &roles); err != nil {
+			return nil, errors.Wrap(err, "expected a JSON array of strings")
+		}
+	} else {
+		roles = strings.Split(val, ",")
+	}
+
+	return normaliseRoles(roles), nil
+}
+
+// normaliseRoles returns the roles trimmed of whitespace, without empty or duplicate roles.
+func normaliseRoles(roles []string) []string {
+	var normalised []string
+
+	for _, role := range roles {
+		if role = strings.TrimSpace(role); role != "" {
+			normalised = appendUnique(normalised, role)
+		}
+	}
+
+	return normalised
+}
+
+type mspRoleSource map[string][]string
+
+// Roles returns the roles of the user's MSP.
+func (s mspRoleSource) Roles(stub shim.ChaincodeStubInterface, clientIdentity cid.ClientIdentity) ([]string, error) {
+	mspID, err := clientIdentity.GetMSPID()
+	if err != nil {
+		return nil, errAuthentication(err)
+	}
+
+	return s[mspID], nil
+}
+
+type certRoleSource []CertRoleRule
+
+// Roles returns the roles derived from the user's certificate.
+func (s certRoleSource) Roles(stub shim.ChaincodeStubInterface, clientIdentity cid.ClientIdentity) ([]string, error) {
+	cert, err := clientIdentity.GetX509Certificate()
+	if err != nil {
+		return nil, errAuthentication(err)
+	}
+
+	return certRoles(cert, s), nil
+}
+
+// validate checks the rules when the AuthService is created.
+func (s certRoleSource) validate() error {
+	return validateCertRoleRules(s)
+}
+
+type ledgerRoles struct{}
+
+// Roles returns the roles assigned to the user in the world state.
+func (s ledgerRoles) Roles(stub shim.ChaincodeStubInterface, clientIdentity cid.ClientIdentity) ([]string, error) {
+	userID, err := clientIdentity.GetID()
+	if err != nil {
+		return nil, errAuthentication(err)
+	}
+
+	rec, err := loadRoleAssignment(stub, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return rec.Roles, nil
+}
+
+// getUserRoles returns the user's roles from the role sources, merged with the AuthService's RoleMerge.
+// The roles attribute is the first source, followed by the sources given as Options.
+// In strict mode, roles which aren't in the RolePermissions are rejected.
+func (a AuthService) getUserRoles(clientIdentity cid.ClientIdentity, rolesAttr string) ([]string, error) {
+	var roles []string
+
+	sources := append([]RoleSource{AttributeRoles(rolesAttr)}, a.roleSources...)
+
+	for _, source := range sources {
+		sourceRoles, err := source.Roles(a.stub, clientIdentity)
+		if err != nil {
+			if _, ok := err.(AuthErrorInterface); !ok {
+				err = errAuthentication(err)
+			}
+
+			return nil, err
+		}
+
+		roles = appendUnique(roles, normaliseRoles(sourceRoles)...)
+
+		if a.roleMerge == RoleMergeFirst && len(roles) > 0 {
+			break
+		}
+	}
+
+	if len(roles) == 0 {
+		return nil, errRoles(rolesAttr)
+	}
+
+	if a.strictRoles {
+		for _, role := range roles {
+			if _, ok := a.rolePermissions[role]; !ok {
+				return nil, errUnknownRole(role)
+			}
+		}
+	}
+
+	return roles, nil
+}
+
+// validateRoleSources checks the configuration of any role sources which can be validated.
+func validateRoleSources(sources []RoleSource) error {
+	for _, source := range sources {
+		if v, ok := source.(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,442 @@
+package rbac_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+var mspRoles = map[string][]string{
+	"Org1MSP":  {"user"},
+	"AuditMSP": {"auditor"},
+}
+
+func TestMSPRoles(t *testing.T) {
+	tests := []struct {
+		mspID    string
+		cidRoles string
+		expRoles []string
+		msg      string
+	}{
+		{
+			mspID:    "AuditMSP",
+			expRoles: []string{"auditor"},
+			msg:      "Should give users of a mapped MSP its roles without a roles attribute",
+		},
+		{
+			mspID:    "Org1MSP",
+			cidRoles: "admin,user",
+			expRoles: []string{"admin", "user"},
+			msg:      "Should combine the roles attribute with the MSP's roles",
+		},
+		{
+			mspID:    "Org2MSP",
+			cidRoles: "admin",
+			expRoles: []string{"admin"},
+			msg:      "Should use only the roles attribute for an unmapped MSP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		cid := newMockCID(identity{roles: tt.cidRoles, mspID: tt.mspID})
+
+		appAuth, err := rbac.New(initEmptyStub(), cid, getRolePerms(), "roles", rbac.WithMSPRoles(mspRoles))
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expRoles, appAuth.GetUserRoles())
+		}
+	}
+
+	t.Log("Should allow an MSP's users to query as the MSP's roles")
+
+	cid := newMockCID(identity{mspID: "AuditMSP"})
+
+	appAuth, err := rbac.New(initEmptyStub(), cid, getRolePerms(), "roles", rbac.WithMSPRoles(mspRoles))
+	if assert.NoError(t, err) {
+		payload, err := appAuth.ValidateQueryPerms(doctypeQuery(resourceTransfer))
+		assert.NoError(t, err)
+		assert.JSONEq(t, expQueryCompleted, payload)
+	}
+}
+
+func TestMSPRolesErrors(t *testing.T) {
+	t.Log("Should return an error when the MSP isn't mapped and there is no roles attribute")
+
+	cid := newMockCID(identity{mspID: "Org2MSP"})
+
+	_, err := rbac.New(initEmptyStub(), cid, getRolePerms(), "roles", rbac.WithMSPRoles(mspRoles))
+
+	if assert.Error(t, err) {
+		if e, ok := err.(rbac.AuthErrorInterface); ok {
+			assert.Equal(t, int32(rbac.CodeErrRoles), e.Code())
+			assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+		}
+	}
+}
+
+var certRoleRules = []rbac.CertRoleRule{
+	{Field: rbac.CertFieldOU, Pattern: "admin", Roles: []string{"admin"}},
+	{Field: rbac.CertFieldOU, Pattern: "finance", Roles: []string{"auditor"}},
+	{Field: rbac.CertFieldEmail, Pattern: "*@org1.example.com", Roles: []string{"user"}},
+	{Field: rbac.CertFieldURI, Pattern: "spiffe://org1.example.com/*/admin", Roles: []string{"admin"}},
+}
+
+func TestCertRoles(t *testing.T) {
+	financeCert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"client", "finance"}},
+		EmailAddresses: []string{"alice@org1.example.com"},
+	}
+
+	workloadCert := func(uri string) *x509.Certificate {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatalf("Parsing the URI failed unexpectedly: %v", err)
+		}
+
+		return &x509.Certificate{Subject: pkix.Name{CommonName: "workload"}, URIs: []*url.URL{u}}
+	}
+
+	tests := []struct {
+		cert     *x509.Certificate
+		cidRoles string
+		expRoles []string
+		msg      string
+	}{
+		{
+			cert:     financeCert,
+			expRoles: []string{"auditor", "user"},
+			msg:      "Should derive roles from the certificate's OUs and SANs without a roles attribute",
+		},
+		{
+			cert:     financeCert,
+			cidRoles: "admin",
+			expRoles: []string{"admin", "auditor", "user"},
+			msg:      "Should combine the roles attribute with the certificate's roles",
+		},
+		{
+			cert:     nil,
+			cidRoles: "admin",
+			expRoles: []string{"admin"},
+			msg:      "Should use only the roles attribute for an identity without an X.509 certificate",
+		},
+		{
+			cert:     workloadCert("spiffe://org1.example.com/ns/finance/admin"),
+			expRoles: []string{"admin"},
+			msg:      "Should match a URI SAN with a `*` which spans several path segments",
+		},
+		{
+			cert:     workloadCert("spiffe://org2.example.com/ns/finance/admin"),
+			cidRoles: "user",
+			expRoles: []string{"user"},
+			msg:      "Should not match a URI SAN which only matches part of the pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		cid := newMockCID(identity{roles: tt.cidRoles, cert: tt.cert})
+
+		appAuth, err := rbac.New(initEmptyStub(), cid, getRolePerms(), "roles", rbac.WithCertRoles(certRoleRules...))
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expRoles, appAuth.GetUserRoles())
+		}
+	}
+}
+
+func TestCertRolesErrors(t *testing.T) {
+	tests := []struct {
+		rules []rbac.CertRoleRule
+		expSC int32
+		expC  int32
+		msg   string
+	}{
+		{
+			rules: certRoleRules,
+			expSC: http.StatusForbidden,
+			expC:  rbac.CodeErrRoles,
+			msg:   "when the certificate doesn't map to any roles and there is no roles attribute",
+		},
+		{
+			rules: []rbac.CertRoleRule{{Field: "ST", Pattern: "*", Roles: []string{"user"}}},
+			expSC: http.StatusInternalServerError,
+			expC:  rbac.CodeErrConfig,
+			msg:   "when a rule's certificate field is unknown",
+		},
+		{
+			rules: []rbac.CertRoleRule{{Field: rbac.CertFieldOU, Pattern: "[", Roles: []string{"user"}}},
+			expSC: http.StatusInternalServerError,
+			expC:  rbac.CodeErrConfig,
+			msg:   "when a rule's pattern is malformed",
+		},
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"peer"}}}
+
+	for _, tt := range tests {
+		cid := newMockCID(identity{cert: cert})
+
+		_, err := rbac.New(initEmptyStub(), cid, getRolePerms(), "roles", rbac.WithCertRoles(tt.rules...))
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v", tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
+
+type staticRoles []string
+
+func (s staticRoles) Roles(stub shim.ChaincodeStubInterface, clientIdentity cid.ClientIdentity) ([]string, error) {
+	return s, nil
+}
+
+type failingRoles struct{}
+
+func (s failingRoles) Roles(stub shim.ChaincodeStubInterface, clientIdentity cid.ClientIdentity) ([]string, error) {
+	return nil, errors.New("role service unavailable")
+}
+
+func ledgerStub(t *testing.T, userID string, roles ...string) shim.ChaincodeStubInterface {
+	stub := initEmptyStub()
+	stub.MockTransactionStart("tx")
+
+	key, err := stub.CreateCompositeKey("rbac~roles", []string{userID})
+	if err != nil {
+		t.Fatalf("CreateCompositeKey failed unexpectedly")
+	}
+
+	b, err := json.Marshal(rbac.RoleAssignment{Roles: roles})
+	if err != nil {
+		t.Fatalf("Marshal failed unexpectedly")
+	}
+
+	if err := stub.PutState(key, b); err != nil {
+		t.Fatalf("PutState failed unexpectedly")
+	}
+
+	return stub
+}
+
+func TestRoleSources(t *testing.T) {
+	tests := []struct {
+		stub     shim.ChaincodeStubInterface
+		cidRoles string
+		opts     []rbac.Option
+		expRoles []string
+		msg      string
+	}{
+		{
+			stub:     initEmptyStub(),
+			opts:     []rbac.Option{rbac.WithRoleSources(staticRoles{"auditor"})},
+			expRoles: []string{"auditor"},
+			msg:      "Should give the user the roles from a custom source without a roles attribute",
+		},
+		{
+			stub:     ledgerStub(t, "testuserID", "auditor", "user"),
+			cidRoles: "user",
+			opts:     []rbac.Option{rbac.WithRoleSources(rbac.LedgerRoles())},
+			expRoles: []string{"user", "auditor"},
+			msg:      "Should combine the roles attribute with the roles assigned in the world state",
+		},
+		{
+			stub:     ledgerStub(t, "otheruserID", "admin"),
+			cidRoles: "user",
+			opts:     []rbac.Option{rbac.WithRoleSources(rbac.LedgerRoles())},
+			expRoles: []string{"user"},
+			msg:      "Should ignore the roles assigned to other users in the world state",
+		},
+		{
+			stub:     initEmptyStub(),
+			opts:     []rbac.Option{rbac.WithRoleSources(staticRoles{}, staticRoles{"auditor"}, staticRoles{"admin"})},
+			expRoles: []string{"auditor", "admin"},
+			msg:      "Should union the roles from all sources by default",
+		},
+		{
+			stub: initEmptyStub(),
+			opts: []rbac.Option{
+				rbac.WithRoleSources(staticRoles{}, staticRoles{"auditor"}, staticRoles{"admin"}),
+				rbac.WithRoleMerge(rbac.RoleMergeFirst),
+			},
+			expRoles: []string{"auditor"},
+			msg:      "Should use only the first source with roles with RoleMergeFirst",
+		},
+		{
+			stub:     initEmptyStub(),
+			cidRoles: "user",
+			opts: []rbac.Option{
+				rbac.WithRoleSources(staticRoles{"admin"}),
+				rbac.WithRoleMerge(rbac.RoleMergeFirst),
+			},
+			expRoles: []string{"user"},
+			msg:      "Should give the roles attribute precedence over other sources with RoleMergeFirst",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		appAuth, err := rbac.New(tt.stub, newMockCID(identity{roles: tt.cidRoles}), getRolePerms(), "roles", tt.opts...)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expRoles, appAuth.GetUserRoles())
+		}
+	}
+}
+
+func TestRoleSourcesErrors(t *testing.T) {
+	tests := []struct {
+		opts  []rbac.Option
+		expSC int32
+		expC  int32
+		msg   string
+	}{
+		{
+			opts:  []rbac.Option{rbac.WithRoleSources(staticRoles{}, rbac.LedgerRoles())},
+			expSC: http.StatusForbidden,
+			expC:  rbac.CodeErrRoles,
+			msg:   "when no source has any roles for the user",
+		},
+		{
+			opts:  []rbac.Option{rbac.WithRoleSources(failingRoles{})},
+			expSC: http.StatusUnauthorized,
+			expC:  rbac.CodeErrAuthentication,
+			msg:   "when a source fails",
+		},
+		{
+			opts:  []rbac.Option{rbac.WithRoleSources(rbac.CertRoles(rbac.CertRoleRule{Field: "ST", Pattern: "*"}))},
+			expSC: http.StatusInternalServerError,
+			expC:  rbac.CodeErrConfig,
+			msg:   "when a source is misconfigured",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := rbac.New(initEmptyStub(), newMockCID(identity{}), getRolePerms(), "roles", tt.opts...)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v", tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
+
+func TestRolesAttr(t *testing.T) {
+	tests := []struct {
+		cidRoles string
+		expRoles []string
+		msg      string
+	}{
+		{
+			cidRoles: "user, admin",
+			expRoles: []string{"user", "admin"},
+			msg:      "Should trim whitespace around roles",
+		},
+		{
+			cidRoles: "user,,admin,",
+			expRoles: []string{"user", "admin"},
+			msg:      "Should remove empty roles",
+		},
+		{
+			cidRoles: "user,admin,user",
+			expRoles: []string{"user", "admin"},
+			msg:      "Should remove duplicate roles",
+		},
+		{
+			cidRoles: `["user","admin"]`,
+			expRoles: []string{"user", "admin"},
+			msg:      "Should parse a JSON array of roles",
+		},
+		{
+			cidRoles: ` [" user", "", "admin", "user"] `,
+			expRoles: []string{"user", "admin"},
+			msg:      "Should trim, remove empty and remove duplicate roles in a JSON array",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		appAuth := simpleSetup(t, nil, nil, tt.cidRoles)
+		assert.Equal(t, tt.expRoles, appAuth.GetUserRoles())
+	}
+
+	t.Log("Should allow known roles in strict mode")
+
+	appAuth := simpleSetup(t, nil, nil, "user, admin", rbac.WithStrictRoles())
+	assert.Equal(t, []string{"user", "admin"}, appAuth.GetUserRoles())
+}
+
+func TestRolesAttrErrors(t *testing.T) {
+	tests := []struct {
+		cidRoles string
+		opts     []rbac.Option
+		expSC    int32
+		expC     int32
+		msg      string
+	}{
+		{
+			cidRoles: `["user", "admin"`,
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrRolesAttr,
+			msg:      "when the JSON array is malformed",
+		},
+		{
+			cidRoles: `["user", 1]`,
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrRolesAttr,
+			msg:      "when the JSON array holds something other than strings",
+		},
+		{
+			cidRoles: " , ,",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrRoles,
+			msg:      "when the attribute has only empty roles",
+		},
+		{
+			cidRoles: "user,superuser",
+			opts:     []rbac.Option{rbac.WithStrictRoles()},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrUnknownRole,
+			msg:      "when a role is unknown in strict mode",
+		},
+		{
+			cidRoles: "user",
+			opts:     []rbac.Option{rbac.WithStrictRoles(), rbac.WithRoleSources(staticRoles{"superuser"})},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrUnknownRole,
+			msg:      "when a role from another source is unknown in strict mode",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := rbac.New(initEmptyStub(), newMockCID(identity{roles: tt.cidRoles}), getRolePerms(), "roles", tt.opts...)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v", tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package rbac_test
 
-import "github.com/stickypixel/hyperledger/rbac"
+import (
+	"errors"
+	"strconv"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
 
 func allow(userID string, userRoles []string) rbac.QueryRule {
 	return rbac.QueryRule{Allow: true}
@@ -26,6 +31,16 @@ func filterFields(userID string, userRoles []string) rbac.QueryRule {
 	}
 }
 
+func ownBalance(userID string, userRoles []string) rbac.QueryRule {
+	return rbac.QueryRule{
+		Allow:       true,
+		FieldFilter: []string{"balance"},
+		SelectorAppend: rbac.CDBSelector{
+			"createdBy": userID,
+		},
+	}
+}
+
 func inTransfer(userID string, userRoles []string) rbac.QueryRule {
 	return rbac.QueryRule{
 		Allow: true,
@@ -40,3 +55,77 @@ func inTransfer(userID string, userRoles []string) rbac.QueryRule {
 		},
 	}
 }
+
+func completed(userID string, userRoles []string) rbac.QueryRule {
+	return rbac.QueryRule{
+		Allow:       true,
+		FieldFilter: []string{"createdBy", "created", "status"},
+		SelectorAppend: rbac.CDBSelector{
+			"status": "completed",
+		},
+	}
+}
+
+func maxAmount(limit int) rbac.ContractCondition {
+	return func(args []string, userID string, userRoles []string) bool {
+		if len(args) == 0 {
+			return false
+		}
+
+		amount, err := strconv.Atoi(args[0])
+
+		return err == nil && amount <= limit
+	}
+}
+
+func forSelf(args []string, userID string, userRoles []string) bool {
+	return len(args) > 0 && args[0] == userID
+}
+
+func sameOrg(ctx *rbac.RuleContext) (rbac.QueryRule, error) {
+	mspID, err := ctx.MSPID()
+	if err != nil {
+		return rbac.QueryRule{}, err
+	}
+
+	return rbac.QueryRule{
+		Allow: true,
+		SelectorAppend: rbac.CDBSelector{
+			"org": mspID,
+		},
+	}, nil
+}
+
+func sameDepartment(ctx *rbac.RuleContext) (rbac.QueryRule, error) {
+	attrs, err := ctx.Attributes()
+	if err != nil {
+		return rbac.QueryRule{}, err
+	}
+
+	dept, ok := attrs["department"]
+
+	return rbac.QueryRule{
+		Allow: ok,
+		SelectorAppend: rbac.CDBSelector{
+			"department": dept,
+		},
+	}, nil
+}
+
+func activeAtTx(ctx *rbac.RuleContext) (rbac.QueryRule, error) {
+	ts, err := ctx.TxTimestamp()
+	if err != nil {
+		return rbac.QueryRule{}, err
+	}
+
+	return rbac.QueryRule{
+		Allow: true,
+		SelectorAppend: rbac.CDBSelector{
+			"validFrom": rbac.CDBSelector{"$lte": ts.Unix()},
+		},
+	}, nil
+}
+
+func failing(ctx *rbac.RuleContext) (rbac.QueryRule, error) {
+	return rbac.QueryRule{}, errors.New("rule failed")
+}
@@ -0,0 +1,188 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// mergeSelectors returns a new selector which matches only documents matched by both the selector and appendSel.
+// Conditions which don't conflict with the selector are added at the root, so docType and any indexed fields stay
+// where CouchDB expects them. Conflicting conditions are added to a root `$and`, so that they constrain the
+// caller's conditions rather than replacing them. Conditions the selector already has are not added again, so
+// merging an already merged selector returns the same selector.
+func mergeSelectors(selector, appendSel CDBSelector) CDBSelector {
+	merged := make(CDBSelector, len(selector)+len(appendSel))
+	for k, v := range selector {
+		merged[k] = v
+	}
+
+	var conflicts []interface{}
+
+	for k, v := range appendSel {
+		if hasCondition(selector, k, v) {
+			continue
+		}
+
+		if _, ok := merged[k]; !ok {
+			merged[k] = v
+			continue
+		}
+
+		conflicts = append(conflicts, CDBSelector{k: v})
+	}
+
+	if len(conflicts) == 0 {
+		return merged
+	}
+
+	switch and := merged["$and"].(type) {
+	case nil:
+		merged["$and"] = conflicts
+	case []interface{}:
+		// Copy so the caller's slice is never modified
+		merged["$and"] = append(append(make([]interface{}, 0, len(and)+len(conflicts)), and...), conflicts...)
+	default:
+		// An invalid `$and` is preserved as is, so CouchDB rejects the query rather than it being silently fixed
+		merged["$and"] = append([]interface{}{CDBSelector{"$and": and}}, conflicts...)
+	}
+
+	return merged
+}
+
+// ownConditions returns a copy of the selector without the conditions of appendSel it already has, at its root or in
+// its root `$and`. These are the conditions added by a previous merge, so what is left are the caller's conditions.
+func ownConditions(selector, appendSel CDBSelector) CDBSelector {
+	own := make(CDBSelector, len(selector))
+	for k, v := range selector {
+		own[k] = v
+	}
+
+	and, isAnd := own["$and"].([]interface{})
+	and = append([]interface{}(nil), and...)
+
+	for k, v := range appendSel {
+		if cur, ok := own[k]; ok && sameJSON(cur, v) {
+			delete(own, k)
+			continue
+		}
+
+		for i, c := range and {
+			if sameJSON(c, CDBSelector{k: v}) {
+				and = append(and[:i], and[i+1:]...)
+				break
+			}
+		}
+	}
+
+	if isAnd {
+		own["$and"] = and
+	}
+
+	return own
+}
+
+// hasCondition returns whether the selector has the condition k: v, at its root or in its root `$and`.
+func hasCondition(selector CDBSelector, k string, v interface{}) bool {
+	if cur, ok := selector[k]; ok && sameJSON(cur, v) {
+		return true
+	}
+
+	and, ok := selector["$and"].([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, c := range and {
+		if sameJSON(c, CDBSelector{k: v}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameJSON returns whether a and b marshal to the same JSON, so that values built in Go can be compared with
+// values decoded from a query.
+func sameJSON(a, b interface{}) bool {
+	aj, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bj, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(aj, bj)
+}
+
+// projectDocument returns a copy of the document containing only the given dotted field paths.
+func projectDocument(doc map[string]interface{}, fields []string) map[string]interface{} {
+	projected := map[string]interface{}{}
+
+	for _, f := range fields {
+		value, status := getField(doc, f)
+		if status != fieldFound {
+			continue
+		}
+
+		keys := splitPath(f)
+		m := projected
+
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := m[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				m[key] = child
+			}
+
+			m = child
+		}
+
+		m[keys[len(keys)-1]] = value
+	}
+
+	return projected
+}
+
+// omitFields returns the document without the fields, i.e. the part of the document which a field filter hides.
+// Objects which are left empty by removing their fields are removed too. The document isn't modified.
+func omitFields(doc map[string]interface{}, fields []string) map[string]interface{} {
+	omitted := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		omitted[k] = v
+	}
+
+	for _, f := range fields {
+		omitField(omitted, splitPath(f))
+	}
+
+	return omitted
+}
+
+// omitField removes the field at the path of keys from m, copying any objects it changes.
+func omitField(m map[string]interface{}, keys []string) {
+	if len(keys) == 1 {
+		delete(m, keys[0])
+		return
+	}
+
+	child, ok := m[keys[0]].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	copied := make(map[string]interface{}, len(child))
+	for k, v := range child {
+		copied[k] = v
+	}
+
+	omitField(copied, keys[1:])
+
+	if len(copied) == 0 {
+		delete(m, keys[0])
+	} else {
+		m[keys[0]] = copied
+	}
+}
@@ -1,6 +1,7 @@
 package rbac_test
 
 import (
+	"crypto/x509"
 	"testing"
 
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
@@ -25,6 +26,7 @@ const (
 	contractCreateTransfer = "createTransfer"
 	contractCreateWallet   = "createWallet"
 	contractQueryLedger    = "queryLedger"
+	collectionPvt          = "pvt"
 )
 
 /*
@@ -46,6 +48,20 @@ func getRolePerms() rbac.RolePermissions {
 				resourceTransfer: allow,
 				resourceWallet:   disallow,
 			},
+			OperationPermissions: rbac.OperationPermissions{
+				resourceTransfer: {
+					rbac.OperationCreate: true,
+					rbac.OperationRead:   true,
+					rbac.OperationUpdate: true,
+					rbac.OperationDelete: true,
+				},
+				resourceWallet: {
+					rbac.OperationDelete: true,
+				},
+			},
+			CollectionPermissions: rbac.CollectionPermissions{
+				collectionPvt: {Write: true},
+			},
 		},
 		"user": {
 			ContractPermissions: rbac.ContractPermissions{
@@ -56,6 +72,43 @@ func getRolePerms() rbac.RolePermissions {
 				resourceTransfer: inTransfer,
 				resourceWallet:   owner,
 			},
+			HistoryPermissions: rbac.HistoryPermissions{
+				resourceWallet: {Allow: true},
+			},
+			CollectionPermissions: rbac.CollectionPermissions{
+				collectionPvt: {Read: true, Write: true},
+			},
+			OperationPermissions: rbac.OperationPermissions{
+				resourceWallet: {
+					rbac.OperationCreate: true,
+					rbac.OperationRead:   true,
+					rbac.OperationUpdate: true,
+					rbac.OperationDelete: false,
+				},
+			},
+		},
+		"auditor": {
+			QueryPermissions: rbac.QueryPermissions{
+				resourceTransfer: completed,
+				resourceWallet:   disallow,
+			},
+			HistoryPermissions: rbac.HistoryPermissions{
+				resourceTransfer: {Allow: true, IncludeDeletes: true},
+			},
+			CollectionPermissions: rbac.CollectionPermissions{
+				collectionPvt: {Read: true},
+			},
+		},
+		"suspended": {
+			ContractPermissions: rbac.ContractPermissions{
+				contractCreateTransfer: false,
+				contractQueryLedger:    false,
+			},
+			OperationPermissions: rbac.OperationPermissions{
+				resourceWallet: {
+					rbac.OperationCreate: false,
+				},
+			},
 		},
 	}
 }
@@ -106,16 +159,60 @@ func (mc *mockCID) GetAttributeValue(attrName string) (string, bool, error) {
 	return args.String(0), args.Bool(1), args.Error(2)
 }
 
-func simpleSetup(t *testing.T, userRoles string) rbac.AuthServiceInterface {
-	stub := initEmptyStub()
+func (mc *mockCID) GetMSPID() (string, error) {
+	args := mc.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (mc *mockCID) GetX509Certificate() (*x509.Certificate, error) {
+	args := mc.Called()
+	return args.Get(0).(*x509.Certificate), args.Error(1)
+}
+
+// identity describes the mocked identity of a test user. The user ID defaults to testuserID, and the roles attribute
+// isn't found if roles is empty.
+type identity struct {
+	userID string
+	roles  string
+	mspID  string
+	cert   *x509.Certificate
+}
+
+// newMockCID returns a mockCID for the identity.
+func newMockCID(id identity) *mockCID {
+	if id.userID == "" {
+		id.userID = "testuserID"
+	}
+
 	cid := new(mockCID)
-	cid.On("GetAttributeValue", "roles").Return(userRoles, true, nil)
-	cid.On("GetID").Return("testuserID")
+	cid.On("GetAttributeValue", "roles").Return(id.roles, id.roles != "", nil)
+	cid.On("GetID").Return(id.userID)
+	cid.On("GetMSPID").Return(id.mspID, nil)
+	cid.On("GetX509Certificate").Return(id.cert, nil)
+
+	return cid
+}
 
-	appAuth, err := rbac.New(stub, cid, getRolePerms(), mock.Anything)
+// simpleSetup returns an AuthService for testuserID with the roles, using an empty stub and getRolePerms() when the
+// stub or RolePermissions are nil.
+func simpleSetup(
+	t *testing.T,
+	stub shim.ChaincodeStubInterface,
+	rp rbac.RolePermissions,
+	userRoles string,
+	opts ...rbac.Option,
+) rbac.AuthService {
+	if stub == nil {
+		stub = initEmptyStub()
+	}
+
+	if rp == nil {
+		rp = getRolePerms()
+	}
 
+	appAuth, err := rbac.New(stub, newMockCID(identity{roles: userRoles}), rp, "roles", opts...)
 	if err != nil {
-		t.Fatalf("New appAuth failed unexpectedly")
+		t.Fatalf("New appAuth failed unexpectedly: %v", err)
 	}
 
 	return appAuth
@@ -0,0 +1,92 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// GetState returns the document stored under key, giving reads by key the same guarantees as rich queries.
+// The user must be able to query the document's docType, the document must match the selector of the user's
+// query rule and only the fields allowed by the rule are returned. Returns nil if the key doesn't exist.
+func (a AuthService) GetState(key string) ([]byte, error) {
+	value, err := a.stub.GetState(key)
+	if err != nil {
+		return nil, errLedger(err)
+	}
+
+	return a.filterRead(key, value)
+}
+
+// filterRead applies the user's query rule to a document read by key.
+// Returns an error if the user isn't allowed to see the document, or nil if there is no document.
+func (a AuthService) filterRead(key string, value []byte) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	filtered, docType, err := a.filterDocument(key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if filtered == nil {
+		return nil, errQuery(docType)
+	}
+
+	return filtered, nil
+}
+
+// filterDocument applies the user's query rule for the document's docType to the document.
+// Returns a nil document, without an error, if the user isn't allowed to see the document.
+func (a AuthService) filterDocument(key string, value []byte) ([]byte, string, error) {
+	doc, err := decodeDocument(key, value)
+	if err != nil {
+		return nil, "", err
+	}
+
+	docType, ok := doc["docType"].(string)
+	if !ok || docType == "" {
+		return nil, "", errDocType(key)
+	}
+
+	rule, ok, err := a.queryRule(docType)
+	if err != nil || !ok {
+		return nil, docType, err
+	}
+
+	match, err := rule.SelectorAppend.Matches(doc)
+	if err != nil || !match {
+		return nil, docType, err
+	}
+
+	if rule.FieldFilter == nil {
+		return value, docType, nil
+	}
+
+	filtered, err := json.Marshal(projectDocument(doc, rule.FieldFilter))
+	if err != nil {
+		return nil, docType, errMarshal(err)
+	}
+
+	return filtered, docType, nil
+}
+
+// decodeDocument decodes the JSON document stored under key. Numbers are decoded as json.Number, so that a
+// filtered document has exactly the same numbers as the stored one.
+func decodeDocument(key string, value []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+
+	if err := dec.Decode(&doc); err != nil {
+		return nil, errDocType(key)
+	}
+
+	if dec.Decode(&struct{}{}) != io.EOF {
+		return nil, errDocType(key)
+	}
+
+	return doc, nil
+}
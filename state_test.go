@@ -0,0 +1,150 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+const (
+	assetDoc         = `{"docType": "asset", "createdBy": "anotherUserID", "created": 1600000000, "salary": 100000}`
+	otherWalletDoc   = `{"docType": "wallet", "createdBy": "anotherUserID", "balance": 10}`
+	toTransferDoc    = `{"docType": "transfer", "createdBy": "anotherUserID", "asset": {"to": "testuserID"}}`
+	otherTransferDoc = `{"docType": "transfer", "createdBy": "anotherUserID", "status": "pending"}`
+	doneTransferDoc  = `{"docType": "transfer", "createdBy": "anotherUserID", "created": 1, "status": "completed"}`
+)
+
+func TestGetState(t *testing.T) {
+	tests := []struct {
+		cidRoles string
+		value    string
+		expPL    string
+		msg      string
+	}{
+		{
+			cidRoles: "user",
+			value:    walletDoc,
+			expPL:    walletDoc,
+			msg:      "Should return a wallet created by the user",
+		},
+		{
+			cidRoles: "user",
+			value:    toTransferDoc,
+			expPL:    toTransferDoc,
+			msg:      "Should return a transfer to the user",
+		},
+		{
+			cidRoles: "admin",
+			value:    assetDoc,
+			expPL:    `{"createdBy": "anotherUserID", "created": 1600000000}`,
+			msg:      "Should return only the allowed fields of an asset",
+		},
+		{
+			cidRoles: "auditor",
+			value:    doneTransferDoc,
+			expPL:    `{"createdBy": "anotherUserID", "created": 1, "status": "completed"}`,
+			msg:      "Should return only the allowed fields of a completed transfer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		stub := initEmptyStub()
+		stub.MockTransactionStart("tx")
+		assert.NoError(t, stub.PutState("k1", []byte(tt.value)))
+
+		appAuth := simpleSetup(t, stub, nil, tt.cidRoles)
+		payload, err := appAuth.GetState("k1")
+
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, tt.expPL, string(payload))
+		}
+	}
+
+	t.Log("Should return integers above 2^53 and decimals exactly in a filtered document")
+
+	stub := initEmptyStub()
+	stub.MockTransactionStart("tx")
+	assert.NoError(t, stub.PutState("k1", []byte(`{"docType": "asset", "createdBy": 9007199254740993, "created": 0.10}`)))
+
+	payload, err := simpleSetup(t, stub, nil, "admin").GetState("k1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"created":0.10,"createdBy":9007199254740993}`, string(payload))
+	}
+
+	t.Log("Should return nothing for a key which doesn't exist")
+
+	payload, err = simpleSetup(t, initEmptyStub(), nil, "user").GetState("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, payload)
+}
+
+func TestGetStateErrors(t *testing.T) {
+	tests := []struct {
+		cidRoles string
+		value    string
+		expSC    int32
+		expC     int32
+		msg      string
+	}{
+		{
+			cidRoles: "user",
+			value:    otherWalletDoc,
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrQuery,
+			msg:      "when the wallet wasn't created by the user",
+		},
+		{
+			cidRoles: "user",
+			value:    otherTransferDoc,
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrQuery,
+			msg:      "when the user isn't involved in the transfer",
+		},
+		{
+			cidRoles: "auditor",
+			value:    otherTransferDoc,
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrQuery,
+			msg:      "when the transfer isn't completed",
+		},
+		{
+			cidRoles: "admin",
+			value:    walletDoc,
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrQuery,
+			msg:      "when the docType is forbidden",
+		},
+		{
+			cidRoles: "admin",
+			value:    `["not", "an", "object"]`,
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocType,
+			msg:      "when the document isn't a JSON object",
+		},
+	}
+
+	for _, tt := range tests {
+		stub := initEmptyStub()
+		stub.MockTransactionStart("tx")
+		assert.NoError(t, stub.PutState("k1", []byte(tt.value)))
+
+		appAuth := simpleSetup(t, stub, nil, tt.cidRoles)
+		payload, err := appAuth.GetState("k1")
+
+		assert.Nil(t, payload)
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v", tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package rbac
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
+)
+
+// Contract names of the policy administration ContractFuncs, granted to the PolicyStore's bootstrap role.
+const (
+	ContractGetPolicy = "getPolicy"
+	ContractSetPolicy = "setPolicy"
+)
+
+// policyObjectType is the composite key object type the policy is stored under.
+// Composite keys live in their own namespace so the policy can not collide with application keys, and the
+// AuthorizedStub doesn't allow keys in the rbac~ namespace to be written.
+const policyObjectType = "rbac~policy"
+
+// PolicyRecord describes a Policy as it is persisted in the world state.
+// Every change increments the Version so that the ledger history of the record is an audit trail.
+type PolicyRecord struct {
+	Version   uint64 `json:"version"`
+	UpdatedBy string `json:"updatedBy,omitempty"`
+	TxID      string `json:"txId,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Policy    Policy `json:"policy"`
+}
+
+// PolicyStore persists a Policy in the world state and provides ContractFuncs to administer it.
+type PolicyStore struct {
+	// BootstrapRole is always allowed to get and set the policy, regardless of the stored policy.
+	BootstrapRole string
+}
+
+// NewPolicyStore returns a PolicyStore which is administered by the bootstrap role.
+func NewPolicyStore(bootstrapRole string) PolicyStore {
+	return PolicyStore{BootstrapRole: bootstrapRole}
+}
+
+// New returns a concrete AuthService type using the policy stored in the world state.
+// If no policy has been stored yet, only the bootstrap role has any permissions.
+func (s PolicyStore) New(
+	stub shim.ChaincodeStubInterface,
+	clientIdentity cid.ClientIdentity,
+	rolesAttr string,
+	opts ...Option,
+) (AuthService, error) {
+	rec, err := s.Load(stub)
+	if err != nil {
+		return AuthService{}, err
+	}
+
+	rolePermissions, err := rec.Policy.RolePermissions()
+	if err != nil {
+		return AuthService{}, err
+	}
+
+	perms := rolePermissions[s.BootstrapRole]
+	if perms.ContractPermissions == nil {
+		perms.ContractPermissions = ContractPermissions{}
+	}
+
+	perms.ContractPermissions[ContractGetPolicy] = true
+	perms.ContractPermissions[ContractSetPolicy] = true
+	rolePermissions[s.BootstrapRole] = perms
+
+	return New(stub, clientIdentity, rolePermissions, rolesAttr, opts...)
+}
+
+// Load returns the PolicyRecord stored in the world state, or an empty record with version 0 if there is none.
+func (s PolicyStore) Load(stub shim.ChaincodeStubInterface) (PolicyRecord, error) {
+	var rec PolicyRecord
+
+	key, err := stub.CreateCompositeKey(policyObjectType, nil)
+	if err != nil {
+		return rec, errLedger(err)
+	}
+
+	b, err := stub.GetState(key)
+	if err != nil {
+		return rec, errLedger(err)
+	}
+
+	if b == nil {
+		return rec, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&rec); err != nil {
+		return PolicyRecord{}, errPolicy(err)
+	}
+
+	return rec, nil
+}
+
+// Save validates the policy and stores it in the world state as a new version.
+func (s PolicyStore) Save(stub shim.ChaincodeStubInterface, p Policy, updatedBy string) (PolicyRecord, error) {
+	if _, err := p.RolePermissions(); err != nil {
+		return PolicyRecord{}, err
+	}
+
+	rec, err := s.Load(stub)
+	if err != nil {
+		return rec, err
+	}
+
+	rec = PolicyRecord{
+		Version:   rec.Version + 1,
+		UpdatedBy: updatedBy,
+		TxID:      stub.GetTxID(),
+		Timestamp: txTimestamp(stub),
+		Policy:    p,
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return rec, errMarshal(err)
+	}
+
+	key, err := stub.CreateCompositeKey(policyObjectType, nil)
+	if err != nil {
+		return rec, errLedger(err)
+	}
+
+	if err := stub.PutState(key, b); err != nil {
+		return rec, errLedger(err)
+	}
+
+	return rec, nil
+}
+
+// GetPolicy is a ContractFunc which returns the stored PolicyRecord. Only the bootstrap role may invoke it.
+func (s PolicyStore) GetPolicy(
+	stub shim.ChaincodeStubInterface,
+	args []string,
+	auth AuthServiceInterface,
+) ([]byte, error) {
+	if !contains(auth.GetUserRoles(), s.BootstrapRole) {
+		return nil, errContract()
+	}
+
+	rec, err := s.Load(unwrapStub(stub))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, errMarshal(err)
+	}
+
+	return b, nil
+}
+
+// SetPolicy is a ContractFunc which stores the JSON or YAML policy document in args[0] as a new version.
+// Only the bootstrap role may invoke it.
+func (s PolicyStore) SetPolicy(
+	stub shim.ChaincodeStubInterface,
+	args []string,
+	auth AuthServiceInterface,
+) ([]byte, error) {
+	if !contains(auth.GetUserRoles(), s.BootstrapRole) {
+		return nil, errContract()
+	}
+
+	if len(args) == 0 {
+		return nil, errPolicy(errors.New("policy document must be provided as the first argument"))
+	}
+
+	var (
+		p   Policy
+		err error
+	)
+
+	if strings.HasPrefix(strings.TrimSpace(args[0]), "{") {
+		p, err = ParsePolicyJSON([]byte(args[0]))
+	} else {
+		p, err = ParsePolicyYAML([]byte(args[0]))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := s.Save(unwrapStub(stub), p, auth.GetUserID())
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, errMarshal(err)
+	}
+
+	return b, nil
+}
+
+// txTimestamp returns the transaction's timestamp formatted for an audit record, or nothing if it isn't available.
+func txTimestamp(stub shim.ChaincodeStubInterface) string {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil || ts == nil {
+		return ""
+	}
+
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
+
+// unwrapStub returns the stub wrapped by an AuthorizedStub.
+// The policy is not a docType document, so it can't be accessed through an AuthorizedStub.
+func unwrapStub(stub shim.ChaincodeStubInterface) shim.ChaincodeStubInterface {
+	if s, ok := stub.(*AuthorizedStub); ok {
+		return s.Unwrap()
+	}
+
+	return stub
+}
@@ -0,0 +1,155 @@
+package rbac_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+func TestPolicyStore(t *testing.T) {
+	stub := initEmptyStub()
+	store := rbac.NewPolicyStore("superAdmin")
+
+	t.Log("Should only allow the bootstrap role to invoke contracts before a policy is stored")
+
+	appAuth, err := store.New(stub, newMockCID(identity{roles: "user"}), "roles")
+	if assert.NoError(t, err) {
+		assert.Error(t, appAuth.ValidateContractPerms(contractCreateWallet))
+		_, err = appAuth.WithContractAuth(rbac.ContractSetPolicy, []string{policyJSON}, store.SetPolicy)
+		assert.Error(t, err)
+	}
+
+	t.Log("Should allow the bootstrap role to set the policy and record version 1")
+
+	stub.MockTransactionStart("tx1")
+	appAuth, err = store.New(stub, newMockCID(identity{roles: "superAdmin"}), "roles")
+	if assert.NoError(t, err) {
+		payload, err := appAuth.WithContractAuth(rbac.ContractSetPolicy, []string{policyJSON}, store.SetPolicy)
+
+		if assert.NoError(t, err) {
+			var rec rbac.PolicyRecord
+
+			assert.NoError(t, json.Unmarshal(payload, &rec))
+			assert.Equal(t, uint64(1), rec.Version)
+			assert.Equal(t, "testuserID", rec.UpdatedBy)
+			assert.Equal(t, "tx1", rec.TxID)
+		}
+	}
+	stub.MockTransactionEnd("tx1")
+
+	t.Log("Should load the stored policy in New")
+
+	appAuth, err = store.New(stub, newMockCID(identity{roles: "user"}), "roles")
+	if assert.NoError(t, err) {
+		assert.NoError(t, appAuth.ValidateContractPerms(contractCreateWallet))
+		q, err := appAuth.ValidateQueryPerms(doctypeQuery(resourceWallet))
+		assert.NoError(t, err)
+		assert.JSONEq(t, expQueryOnlyCreatedBy(resourceWallet), q)
+	}
+
+	t.Log("Should increment the version when a YAML policy is set")
+
+	stub.MockTransactionStart("tx2")
+	appAuth, err = store.New(stub, newMockCID(identity{roles: "superAdmin"}), "roles")
+	if assert.NoError(t, err) {
+		_, err = appAuth.WithContractAuth(rbac.ContractSetPolicy, []string{policyYAML}, store.SetPolicy)
+		assert.NoError(t, err)
+	}
+	stub.MockTransactionEnd("tx2")
+
+	appAuth, err = store.New(stub, newMockCID(identity{roles: "superAdmin"}), "roles")
+	if assert.NoError(t, err) {
+		payload, err := appAuth.WithContractAuth(rbac.ContractGetPolicy, nil, store.GetPolicy)
+
+		if assert.NoError(t, err) {
+			var rec rbac.PolicyRecord
+
+			assert.NoError(t, json.Unmarshal(payload, &rec))
+			assert.Equal(t, uint64(2), rec.Version)
+			assert.Equal(t, "tx2", rec.TxID)
+			assert.Contains(t, rec.Policy.Roles, "user")
+		}
+	}
+}
+
+func TestPolicyStoreErrors(t *testing.T) {
+	stub := initEmptyStub()
+	store := rbac.NewPolicyStore("superAdmin")
+
+	tests := []struct {
+		args     []string
+		c        rbac.ContractFunc
+		cRef     string
+		cidRoles string
+		expSC    int32
+		expC     int32
+		msg      string
+	}{
+		{
+			args:     nil,
+			c:        store.GetPolicy,
+			cRef:     rbac.ContractGetPolicy,
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrContract,
+			msg:      "when a role other than the bootstrap role gets the policy",
+		},
+		{
+			args:     nil,
+			c:        store.SetPolicy,
+			cRef:     rbac.ContractSetPolicy,
+			cidRoles: "superAdmin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrPolicy,
+			msg:      "when the policy document is missing",
+		},
+		{
+			args:     []string{`{"roles": {"user": {"operations": {"wallet": {"archive": true}}}}}`},
+			c:        store.SetPolicy,
+			cRef:     rbac.ContractSetPolicy,
+			cidRoles: "superAdmin",
+			expSC:    http.StatusBadRequest,
+			expC:     rbac.CodeErrPolicy,
+			msg:      "when the policy document is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Logf("Should return an error with code %v and HTTP status code %v %v", tt.expC, tt.expSC, tt.msg)
+
+		stub.MockTransactionStart("tx")
+		appAuth, err := store.New(stub, newMockCID(identity{roles: tt.cidRoles}), "roles")
+		if assert.NoError(t, err) {
+			_, err = appAuth.WithContractAuth(tt.cRef, tt.args, tt.c)
+		}
+		stub.MockTransactionEnd("tx")
+
+		if assert.Error(t, err) {
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+	}
+}
+
+func TestPolicyStoreLoadErrors(t *testing.T) {
+	t.Log("Should return an error with code 4004 when the stored policy record has unknown fields")
+
+	stub := initEmptyStub()
+	stub.MockTransactionStart("tx")
+	assert.NoError(t, stub.PutState(policyKey, []byte(policyDoc)))
+	stub.MockTransactionEnd("tx")
+
+	_, err := rbac.NewPolicyStore("superAdmin").Load(stub)
+
+	if assert.Error(t, err) {
+		if e, ok := err.(rbac.AuthErrorInterface); ok {
+			assert.Equal(t, int32(rbac.CodeErrPolicy), e.Code())
+		}
+	}
+}
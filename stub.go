@@ -0,0 +1,324 @@
+package rbac
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// AuthorizedStub wraps a shim.ChaincodeStubInterface and enforces the user's permissions on state access.
+// Rich queries are rewritten with ValidateQueryPerms, reads by key, range and composite key are filtered by the user's
+// query rule for the document's docType and writes require the create, update or delete operation on the document's
+// docType. Private data is authorised in the same way, once the user has permission to the collection.
+// Keys in the reserved rbac~ composite key namespace, such as the policy and role assignments, can't be written.
+// Any methods which are not overridden are passed through to the wrapped stub.
+type AuthorizedStub struct {
+	shim.ChaincodeStubInterface
+	auth AuthService
+}
+
+// reservedKeyPrefix is the prefix of the composite keys this package stores its own records under.
+// Composite keys start with a null character, followed by the object type.
+const reservedKeyPrefix = "\x00rbac~"
+
+// AuthorizedStub returns a stub which enforces the user's permissions on state access.
+func (a AuthService) AuthorizedStub() *AuthorizedStub {
+	return &AuthorizedStub{
+		ChaincodeStubInterface: a.stub,
+		auth:                   a,
+	}
+}
+
+// Unwrap returns the wrapped stub, which does not enforce any permissions.
+func (s *AuthorizedStub) Unwrap() shim.ChaincodeStubInterface {
+	return s.ChaincodeStubInterface
+}
+
+// GetQueryResult enforces the user's query permissions on the query before it is executed.
+func (s *AuthorizedStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	q, err := s.auth.ValidateQueryPerms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ChaincodeStubInterface.GetQueryResult(q)
+}
+
+// GetQueryResultWithPagination enforces the user's query permissions on the query before it is executed.
+func (s *AuthorizedStub) GetQueryResultWithPagination(
+	query string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	q, err := s.auth.ValidateQueryPerms(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.ChaincodeStubInterface.GetQueryResultWithPagination(q, pageSize, bookmark)
+}
+
+// GetState returns the value of the key, with the user's query rule for the document's docType applied.
+func (s *AuthorizedStub) GetState(key string) ([]byte, error) {
+	return s.auth.GetState(key)
+}
+
+// GetStateByRange returns an iterator over the key range, with the user's query rules applied to each document.
+func (s *AuthorizedStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return s.auth.GetStateByRange(startKey, endKey)
+}
+
+// GetStateByRangeWithPagination returns an iterator over a page of the key range, with the user's query rules
+// applied to each document.
+func (s *AuthorizedStub) GetStateByRangeWithPagination(
+	startKey, endKey string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return s.auth.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+}
+
+// GetStateByPartialCompositeKey returns an iterator over the partial composite key, with the user's query rules
+// applied to each document.
+func (s *AuthorizedStub) GetStateByPartialCompositeKey(
+	objectType string,
+	keys []string,
+) (shim.StateQueryIteratorInterface, error) {
+	return s.auth.GetStateByPartialCompositeKey(objectType, keys)
+}
+
+// GetStateByPartialCompositeKeyWithPagination returns an iterator over a page of the partial composite key,
+// with the user's query rules applied to each document.
+func (s *AuthorizedStub) GetStateByPartialCompositeKeyWithPagination(
+	objectType string,
+	keys []string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return s.auth.GetStateByPartialCompositeKeyWithPagination(objectType, keys, pageSize, bookmark)
+}
+
+// GetHistoryForKey returns an iterator over the history of the key, with the user's history and query rules applied
+// to each version.
+func (s *AuthorizedStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return s.auth.GetHistoryForKey(key)
+}
+
+// PutState writes the value if the user has permission to create or update the document's docType.
+// If an existing document's docType is changed, the user needs permission to delete the existing docType
+// and create the new one.
+func (s *AuthorizedStub) PutState(key string, value []byte) error {
+	if err := checkReservedKey(key); err != nil {
+		return err
+	}
+
+	if len(value) == 0 {
+		return s.DelState(key)
+	}
+
+	existing, err := s.ChaincodeStubInterface.GetState(key)
+	if err != nil {
+		return errLedger(err)
+	}
+
+	if err := s.auth.authorizePut(key, existing, value); err != nil {
+		return err
+	}
+
+	return s.ChaincodeStubInterface.PutState(key, value)
+}
+
+// DelState deletes the key if the user has permission to delete the document's docType.
+func (s *AuthorizedStub) DelState(key string) error {
+	if err := checkReservedKey(key); err != nil {
+		return err
+	}
+
+	existing, err := s.ChaincodeStubInterface.GetState(key)
+	if err != nil {
+		return errLedger(err)
+	}
+
+	if err := s.auth.authorizeWrite(key, existing, ""); err != nil {
+		return err
+	}
+
+	return s.ChaincodeStubInterface.DelState(key)
+}
+
+// SetStateValidationParameter sets the key-level endorsement policy of the key if the user has permission to
+// update the document stored under it, in the same way as PutState.
+func (s *AuthorizedStub) SetStateValidationParameter(key string, ep []byte) error {
+	if err := checkReservedKey(key); err != nil {
+		return err
+	}
+
+	existing, err := s.ChaincodeStubInterface.GetState(key)
+	if err != nil {
+		return errLedger(err)
+	}
+
+	if err := s.auth.authorizeUpdate(key, existing); err != nil {
+		return err
+	}
+
+	return s.ChaincodeStubInterface.SetStateValidationParameter(key, ep)
+}
+
+// checkReservedKey returns an error if the key is in the reserved rbac~ composite key namespace.
+func checkReservedKey(key string) error {
+	if strings.HasPrefix(key, reservedKeyPrefix) {
+		return errReservedKey(key)
+	}
+
+	return nil
+}
+
+// authorizeUpdate validates whether the user can update the existing value in place, e.g. to change its
+// validation parameter.
+func (a AuthService) authorizeUpdate(key string, existing []byte) error {
+	docType, err := docTypeOf(key, existing)
+	if err != nil {
+		return err
+	}
+
+	return a.authorizeWrite(key, existing, docType)
+}
+
+// authorizePut validates whether the user can write the value over the existing value.
+// The value must match the selector of the user's query rule for its docType, so that users can't write documents
+// they wouldn't be able to read. Users without a query rule for the docType can write documents they can't read.
+func (a AuthService) authorizePut(key string, existing, value []byte) error {
+	docType, err := docTypeOf(key, value)
+	if err != nil {
+		return err
+	}
+
+	if err := a.authorizeWrite(key, existing, docType); err != nil {
+		return err
+	}
+
+	rule, ok, err := a.queryRule(docType)
+	if err != nil || !ok {
+		return err
+	}
+
+	doc, err := decodeDocument(key, value)
+	if err != nil {
+		return err
+	}
+
+	match, err := rule.SelectorAppend.Matches(doc)
+	if err != nil {
+		return err
+	}
+
+	if !match {
+		op := OperationUpdate
+		if existing == nil {
+			op = OperationCreate
+		}
+
+		return errDocument(key, op)
+	}
+
+	if existing == nil || rule.FieldFilter == nil {
+		return nil
+	}
+
+	return authorizeHiddenFields(key, existing, doc, docType, rule.FieldFilter)
+}
+
+// authorizeHiddenFields validates that an update of the existing document keeps the fields which the field filter
+// hides from the user unchanged. Documents read through the AuthorizedStub only have the allowed fields, so writing
+// one back would otherwise delete the fields the user can't see.
+func authorizeHiddenFields(
+	key string,
+	existing []byte,
+	doc map[string]interface{},
+	docType string,
+	fields []string,
+) error {
+	existingDoc, err := decodeDocument(key, existing)
+	if err != nil {
+		return err
+	}
+
+	// Replacing the document with another docType deletes it, which doesn't need the hidden fields
+	if existingDoc["docType"] != docType {
+		return nil
+	}
+
+	if !sameJSON(omitFields(existingDoc, fields), omitFields(doc, fields)) {
+		return errHiddenFields(key)
+	}
+
+	return nil
+}
+
+// authorizeWrite validates whether the user can write a document of docType over the existing value.
+// An empty docType means the existing document is being deleted. The user must be able to read the existing
+// document, so that they can't update or delete documents which their query rule hides from them.
+func (a AuthService) authorizeWrite(key string, existing []byte, docType string) error {
+	if existing == nil {
+		if docType == "" {
+			return nil
+		}
+
+		return a.ValidateOperationPerms(docType, OperationCreate)
+	}
+
+	existingDocType, err := docTypeOf(key, existing)
+	if err != nil {
+		return err
+	}
+
+	op := OperationDelete
+
+	switch docType {
+	case "":
+		err = a.ValidateOperationPerms(existingDocType, OperationDelete)
+	case existingDocType:
+		op = OperationUpdate
+		err = a.ValidateOperationPerms(docType, OperationUpdate)
+	default:
+		if err = a.ValidateOperationPerms(existingDocType, OperationDelete); err == nil {
+			err = a.ValidateOperationPerms(docType, OperationCreate)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	visible, _, err := a.filterDocument(key, existing)
+	if err != nil {
+		return err
+	}
+
+	if visible == nil {
+		return errDocument(key, op)
+	}
+
+	return nil
+}
+
+// docTypeOf returns the docType of a JSON document stored under key.
+func docTypeOf(key string, value []byte) (string, error) {
+	var doc struct {
+		DocType interface{} `json:"docType"`
+	}
+
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return "", errDocType(key)
+	}
+
+	docType, ok := doc.DocType.(string)
+	if !ok || docType == "" {
+		return "", errDocType(key)
+	}
+
+	return docType, nil
+}
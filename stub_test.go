@@ -0,0 +1,443 @@
+package rbac_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stickypixel/hyperledger/rbac"
+)
+
+/*
+ *
+ * Create a queryStub which records the queries it is asked to execute, as the MockStub has no query engine
+ *
+ */
+
+type queryStub struct {
+	*shimtest.MockStub
+	queries []string
+}
+
+func (s *queryStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	s.queries = append(s.queries, query)
+	return nil, nil
+}
+
+func (s *queryStub) GetQueryResultWithPagination(
+	query string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	s.queries = append(s.queries, query)
+	return nil, nil, nil
+}
+
+func putContract(key, value string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		return nil, stub.PutState(key, []byte(value))
+	}
+}
+
+func delContract(key string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		return nil, stub.DelState(key)
+	}
+}
+
+func epContract(key, ep string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		if err := stub.SetStateValidationParameter(key, []byte(ep)); err != nil {
+			return nil, err
+		}
+
+		return stub.GetStateValidationParameter(key)
+	}
+}
+
+func getContract(key string) rbac.ContractFunc {
+	return func(stub shim.ChaincodeStubInterface, args []string, auth rbac.AuthServiceInterface) ([]byte, error) {
+		return stub.GetState(key)
+	}
+}
+
+const (
+	walletDoc     = `{"docType": "wallet", "createdBy": "testuserID", "balance": 10}`
+	richWalletDoc = `{"docType": "wallet", "createdBy": "testuserID", "balance": 100}`
+	transferDoc   = `{"docType": "transfer", "createdBy": "testuserID", "amount": 10}`
+	policyKey     = "\x00rbac~policy\x00"
+	policyDoc     = `{"docType": "wallet", "policy": {"roles": {"user": {"contracts": {"*": true}}}}}`
+)
+
+func TestAuthorizedStub(t *testing.T) {
+	tests := []struct {
+		c        rbac.ContractFunc
+		cidRoles string
+		state    map[string]string
+		expState map[string]string
+		expPL    string
+		msg      string
+	}{
+		{
+			c:        putContract("w1", walletDoc),
+			cidRoles: "user",
+			expState: map[string]string{"w1": walletDoc},
+			msg:      "Should allow user to create a wallet",
+		},
+		{
+			c:        putContract("w1", walletDoc),
+			cidRoles: "user",
+			state:    map[string]string{"w1": `{"docType": "wallet", "createdBy": "testuserID"}`},
+			expState: map[string]string{"w1": walletDoc},
+			msg:      "Should allow user to update a wallet",
+		},
+		{
+			c:        delContract("w1"),
+			cidRoles: "user,admin",
+			state:    map[string]string{"w1": walletDoc},
+			expState: map[string]string{},
+			msg:      "Should allow admin to delete a wallet they can read",
+		},
+		{
+			c:        delContract("missing"),
+			cidRoles: "user",
+			expState: map[string]string{},
+			msg:      "Should allow deleting a key which doesn't exist",
+		},
+		{
+			c:        putContract("t1", transferDoc),
+			cidRoles: "user,admin",
+			state:    map[string]string{"t1": walletDoc},
+			expState: map[string]string{"t1": transferDoc},
+			msg:      "Should allow admin to replace a wallet they can read with a transfer",
+		},
+		{
+			c:        epContract("w1", "ep"),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expState: map[string]string{"w1": walletDoc},
+			expPL:    "ep",
+			msg:      "Should allow user to set the validation parameter of a wallet they can update",
+		},
+		{
+			c:        getContract("w1"),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expPL:    walletDoc,
+			msg:      "Should allow user to get a wallet",
+		},
+		{
+			c:        getContract("missing"),
+			cidRoles: "user",
+			msg:      "Should return nothing for a key which doesn't exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Log(tt.msg)
+
+		stub := initEmptyStub()
+		stub.MockTransactionStart("tx")
+
+		for k, v := range tt.state {
+			assert.NoError(t, stub.PutState(k, []byte(v)))
+		}
+
+		appAuth := simpleSetup(t, stub, nil, tt.cidRoles)
+		payload, err := appAuth.WithContractAuth(contractQueryLedger, nil, tt.c)
+		stub.MockTransactionEnd("tx")
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expPL, string(payload))
+
+			if tt.expState != nil {
+				assert.Len(t, stub.State, len(tt.expState))
+			}
+
+			for k, v := range tt.expState {
+				assert.Equal(t, v, string(stub.State[k]))
+			}
+		}
+	}
+}
+
+func TestAuthorizedStubQueries(t *testing.T) {
+	stub := &queryStub{MockStub: initEmptyStub()}
+	appAuth := simpleSetup(t, stub, nil, "user")
+	authStub := appAuth.AuthorizedStub()
+
+	t.Log("Should rewrite queries executed with the AuthorizedStub")
+
+	_, err := authStub.GetQueryResult(doctypeQuery(resourceWallet))
+	assert.NoError(t, err)
+
+	_, _, err = authStub.GetQueryResultWithPagination(doctypeQuery(resourceTransfer), 10, "")
+	assert.NoError(t, err)
+
+	t.Log("Should execute queries which were already validated unchanged")
+
+	q, err := appAuth.ValidateQueryPerms(doctypeQuery(resourceWallet))
+	assert.NoError(t, err)
+
+	_, err = authStub.GetQueryResult(q)
+	assert.NoError(t, err)
+
+	if assert.Len(t, stub.queries, 3) {
+		assert.JSONEq(t, expQueryOnlyCreatedBy(resourceWallet), stub.queries[0])
+		assert.JSONEq(t, expQueryInTransfer, stub.queries[1])
+		assert.Equal(t, q, stub.queries[2])
+	}
+
+	t.Log("Should not execute forbidden queries")
+
+	_, err = appAuth.AuthorizedStub().GetQueryResult(doctypeQuery(resourceAsset))
+	assert.Error(t, err)
+	assert.Len(t, stub.queries, 3)
+}
+
+func TestAuthorizedStubErrors(t *testing.T) {
+	tests := []struct {
+		c        rbac.ContractFunc
+		cidRoles string
+		state    map[string]string
+		expSC    int32
+		expC     int32
+		msg      string
+	}{
+		{
+			c:        putContract("t1", transferDoc),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrOperation,
+			msg:      "when the user creates a forbidden docType",
+		},
+		{
+			c:        putContract("w1", walletDoc),
+			cidRoles: "admin",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrOperation,
+			msg:      "when the user updates a forbidden docType",
+		},
+		{
+			c:        putContract("w1", transferDoc),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrOperation,
+			msg:      "when the user changes a docType they can't delete",
+		},
+		{
+			c:        delContract("w1"),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrOperation,
+			msg:      "when the user deletes a forbidden docType",
+		},
+		{
+			c:        putContract("w1", walletDoc),
+			cidRoles: "user",
+			state:    map[string]string{"w1": otherWalletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocument,
+			msg:      "when the user overwrites another user's wallet",
+		},
+		{
+			c:        putContract("w1", otherWalletDoc),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocument,
+			msg:      "when the user creates a wallet for another user",
+		},
+		{
+			c:        putContract("w1", otherWalletDoc),
+			cidRoles: "user",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocument,
+			msg:      "when the user gives their wallet to another user",
+		},
+		{
+			c:        delContract("w1"),
+			cidRoles: "admin",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocument,
+			msg:      "when the user deletes a wallet they can't read",
+		},
+		{
+			c:        epContract("w1", "ep"),
+			cidRoles: "user",
+			state:    map[string]string{"w1": otherWalletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocument,
+			msg:      "when the user sets the validation parameter of another user's wallet",
+		},
+		{
+			c:        epContract("t1", "ep"),
+			cidRoles: "admin",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocType,
+			msg:      "when the user sets the validation parameter of a key which doesn't exist",
+		},
+		{
+			c:        epContract(policyKey, "ep"),
+			cidRoles: "admin",
+			state:    map[string]string{policyKey: policyDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrReservedKey,
+			msg:      "when the user sets the validation parameter of the stored policy",
+		},
+		{
+			c:        putContract("w1", `not json`),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocType,
+			msg:      "when the value isn't JSON",
+		},
+		{
+			c:        putContract("w1", `{"balance": 10}`),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocType,
+			msg:      "when the value doesn't have a docType",
+		},
+		{
+			c:        putContract(policyKey, policyDoc),
+			cidRoles: "user",
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrReservedKey,
+			msg:      "when the user writes the stored policy",
+		},
+		{
+			c:        delContract(policyKey),
+			cidRoles: "admin",
+			state:    map[string]string{policyKey: policyDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrReservedKey,
+			msg:      "when the user deletes the stored policy",
+		},
+		{
+			c:        getContract("w1"),
+			cidRoles: "admin",
+			state:    map[string]string{"w1": walletDoc},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrQuery,
+			msg:      "when the user gets a forbidden docType",
+		},
+		{
+			c:        getContract("k1"),
+			cidRoles: "admin",
+			state:    map[string]string{"k1": `{"docType": 1}`},
+			expSC:    http.StatusForbidden,
+			expC:     rbac.CodeErrDocType,
+			msg:      "when the user gets a document without a valid docType",
+		},
+	}
+
+	for _, tt := range tests {
+		stub := initEmptyStub()
+		stub.MockTransactionStart("tx")
+
+		for k, v := range tt.state {
+			assert.NoError(t, stub.PutState(k, []byte(v)))
+		}
+
+		appAuth := simpleSetup(t, stub, nil, tt.cidRoles)
+		_, err := appAuth.WithContractAuth(contractQueryLedger, nil, tt.c)
+		stub.MockTransactionEnd("tx")
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v", tt.expC, tt.expSC, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, tt.expSC, e.StatusCode())
+			}
+		}
+
+		for k, v := range tt.state {
+			assert.Equal(t, v, string(stub.State[k]))
+		}
+	}
+}
+
+func TestAuthorizedStubHiddenFields(t *testing.T) {
+	const limitWalletDoc = `{"docType": "wallet", "createdBy": "testuserID", "balance": 10, "limit": {"daily": 500}}`
+
+	rp := rbac.RolePermissions{
+		"clerk": {
+			QueryPermissions: rbac.QueryPermissions{resourceWallet: ownBalance},
+			OperationPermissions: rbac.OperationPermissions{
+				resourceWallet: {rbac.OperationUpdate: true},
+			},
+		},
+	}
+
+	tests := []struct {
+		value string
+		expC  int32
+		msg   string
+	}{
+		{
+			value: `{"docType": "wallet", "createdBy": "testuserID", "balance": 20, "limit": {"daily": 500}}`,
+			msg:   "Should allow the user to change the fields they can see",
+		},
+		{
+			value: `{"docType": "wallet", "limit": {"daily": 500}, "balance": 10.0, "createdBy": "testuserID"}`,
+			msg:   "Should allow the user to reorder the fields and reformat the numbers they can see",
+		},
+		{
+			value: `{"docType": "wallet", "createdBy": "testuserID", "balance": 20}`,
+			expC:  rbac.CodeErrHiddenFields,
+			msg:   "when the user writes back the filtered document, dropping the fields they can't see",
+		},
+		{
+			value: `{"docType": "wallet", "createdBy": "testuserID", "balance": 10, "limit": {"daily": 5000}}`,
+			expC:  rbac.CodeErrHiddenFields,
+			msg:   "when the user changes a field they can't see",
+		},
+		{
+			value: `{"docType": "wallet", "createdBy": "testuserID", "balance": 10, "limit": {"daily": 500}, "x": 1}`,
+			expC:  rbac.CodeErrHiddenFields,
+			msg:   "when the user adds a field they can't see",
+		},
+	}
+
+	for _, tt := range tests {
+		stub := initEmptyStub()
+		stub.MockTransactionStart("tx")
+		assert.NoError(t, stub.PutState("w1", []byte(limitWalletDoc)))
+
+		appAuth := simpleSetup(t, stub, rp, "clerk")
+		err := appAuth.AuthorizedStub().PutState("w1", []byte(tt.value))
+		stub.MockTransactionEnd("tx")
+
+		if tt.expC == 0 {
+			t.Log(tt.msg)
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.value, string(stub.State["w1"]))
+			}
+
+			continue
+		}
+
+		if assert.Error(t, err) {
+			t.Logf("Should return an error with code %v and HTTP status code %v %v\nerr: %v",
+				tt.expC, http.StatusForbidden, tt.msg, err)
+
+			if e, ok := err.(rbac.AuthErrorInterface); ok {
+				assert.Equal(t, tt.expC, e.Code())
+				assert.Equal(t, int32(http.StatusForbidden), e.StatusCode())
+			}
+		}
+
+		assert.Equal(t, limitWalletDoc, string(stub.State["w1"]))
+	}
+}
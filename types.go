@@ -20,16 +20,72 @@ type QueryRuleFunc func(userID string, userRoles []string) QueryRule
 // QueryPermissions maps Resources to QueryRuleFuncs.
 type QueryPermissions map[string]QueryRuleFunc
 
+// QueryRuleContextFunc describes the signature of a rule callback function which is given the RuleContext
+// and can fail. Returning an error fails the query.
+type QueryRuleContextFunc func(ctx *RuleContext) (QueryRule, error)
+
+// QueryContextPermissions maps Resources to QueryRuleContextFuncs.
+type QueryContextPermissions map[string]QueryRuleContextFunc
+
 // ContractFunc describes the signature of a chaincode ContractFunc.
 type ContractFunc func(stub shim.ChaincodeStubInterface, args []string, auth AuthServiceInterface) ([]byte, error)
 
 // ContractPermissions is the base permissions for contract invocation.
 type ContractPermissions map[string]bool
 
+// ContractCondition describes a predicate over a contract's args and the current user, which must be true for the
+// role to invoke the contract.
+type ContractCondition func(args []string, userID string, userRoles []string) bool
+
+// ContractConditions maps contract names to ContractConditions.
+type ContractConditions map[string]ContractCondition
+
+// Operation describes a CRUD style operation which can be performed on a resource.
+type Operation string
+
+// Operations which can be granted on a resource.
+const (
+	OperationCreate Operation = "create"
+	OperationRead   Operation = "read"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
+// OperationPermissions maps Resources to the Operations which can be performed on them.
+type OperationPermissions map[string]map[Operation]bool
+
+// HistoryRule describes whether the history of a resource can be seen and whether deletes are included in it.
+// Each historical version is still filtered by the QueryRule for the resource.
+type HistoryRule struct {
+	Allow          bool `json:"allow"`
+	IncludeDeletes bool `json:"includeDeletes,omitempty"`
+}
+
+// HistoryPermissions maps Resources to HistoryRules.
+type HistoryPermissions map[string]HistoryRule
+
+// CollectionRule describes whether a private data collection can be read and written.
+// Documents in the collection are still subject to the QueryPermissions and OperationPermissions of their docType.
+type CollectionRule struct {
+	Read  bool `json:"read,omitempty"`
+	Write bool `json:"write,omitempty"`
+}
+
+// CollectionPermissions maps private data collection names to CollectionRules.
+type CollectionPermissions map[string]CollectionRule
+
 // Permissions describes the types of permissions the RolePermissions can have.
+// Inherits lists parent roles whose permissions are inherited, in order, with later parents and then the role's
+// own permissions overriding earlier entries.
 type Permissions struct {
 	ContractPermissions
 	QueryPermissions
+	QueryContextPermissions
+	OperationPermissions
+	HistoryPermissions
+	CollectionPermissions
+	ContractConditions
+	Inherits []string
 }
 
 // RolePermissions maps a roles to Permissions.
@@ -38,11 +94,29 @@ type RolePermissions map[string]Permissions
 // CDBSelector describes a CouchDB selector.
 type CDBSelector map[string]interface{}
 
-// CDBQuery describes a CouchDB query.
+// CDBSortField describes a field in a CouchDB sort, with an optional direction of "asc" or "desc".
+type CDBSortField struct {
+	Field     string
+	Direction string
+}
+
+// CDBSort describes a CouchDB sort, which is an ordered array of fields.
+type CDBSort []CDBSortField
+
+// CDBQuery describes a CouchDB Mango query.
+// All of the Mango query fields are modelled so that a query is not changed by being unmarshalled and marshalled.
 type CDBQuery struct {
-	Selector CDBSelector            `json:"selector,omitempty"`
-	Limit    uint                   `json:"limit,omitempty"`
-	Skip     uint                   `json:"skip,omitempty"`
-	Fields   []string               `json:"fields,omitempty"`
-	Sort     map[string]interface{} `json:"sort,omitempty"`
+	Selector       CDBSelector `json:"selector,omitempty"`
+	Limit          uint        `json:"limit,omitempty"`
+	Skip           uint        `json:"skip,omitempty"`
+	Sort           CDBSort     `json:"sort,omitempty"`
+	Fields         []string    `json:"fields,omitempty"`
+	UseIndex       interface{} `json:"use_index,omitempty"`
+	Conflicts      bool        `json:"conflicts,omitempty"`
+	R              uint        `json:"r,omitempty"`
+	Bookmark       string      `json:"bookmark,omitempty"`
+	Update         *bool       `json:"update,omitempty"`
+	Stable         bool        `json:"stable,omitempty"`
+	Stale          string      `json:"stale,omitempty"`
+	ExecutionStats bool        `json:"execution_stats,omitempty"`
 }
@@ -1,20 +1,85 @@
 package rbac
 
 import (
-	"strings"
+	"encoding/json"
 
-	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/pkg/errors"
 )
 
-func getRoles(clientIdentity cid.ClientIdentity, rolesAttr string) ([]string, error) {
-	val, found, err := clientIdentity.GetAttributeValue(rolesAttr)
-	if err != nil {
-		return nil, errAuthentication(err)
+// valid returns whether the Operation is one of the known CRUD operations.
+func (op Operation) valid() bool {
+	switch op {
+	case OperationCreate, OperationRead, OperationUpdate, OperationDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// contains returns whether the value is in the slice.
+func contains(slice []string, value string) bool {
+	for _, v := range slice {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendUnique appends the values to the slice, skipping any which are already in it.
+func appendUnique(slice []string, values ...string) []string {
+	for _, v := range values {
+		if !contains(slice, v) {
+			slice = append(slice, v)
+		}
+	}
+
+	return slice
+}
+
+// intersectFields returns the fields in a which are also in b, in the order of a.
+func intersectFields(a, b []string) []string {
+	fields := []string{}
+
+	for _, f := range a {
+		if contains(b, f) {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// MarshalJSON marshals the sort field as a field name, or as an object of field name to direction.
+func (f CDBSortField) MarshalJSON() ([]byte, error) {
+	if f.Direction == "" {
+		return json.Marshal(f.Field)
+	}
+
+	return json.Marshal(map[string]string{f.Field: f.Direction})
+}
+
+// UnmarshalJSON unmarshals a sort field from a field name, or from an object of field name to direction.
+func (f *CDBSortField) UnmarshalJSON(b []byte) error {
+	var field string
+	if err := json.Unmarshal(b, &field); err == nil {
+		*f = CDBSortField{Field: field}
+		return nil
+	}
+
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return errors.Wrap(err, "sort field must be a string or an object of field name to direction")
+	}
+
+	if len(m) != 1 {
+		return errors.New("sort field object must have exactly one field")
 	}
 
-	if !found {
-		return nil, errRoles(rolesAttr)
+	for field, dir := range m {
+		*f = CDBSortField{Field: field, Direction: dir}
 	}
 
-	return strings.Split(val, ","), nil
+	return nil
 }